@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminHandler returns http.Handler for logging administration:
+//
+//	GET /state: internal state dump, see DumpState()
+//	GET /health: health status, see HealthHandler()
+//	GET /verify?rate=N: verify archives, throttled to N bytes per
+//	  second, see VerifyArchives()
+//	GET /files/: list and download log files, see FilesHandler()
+//	POST /freeze?timeout=D: freeze the log file, auto thawed after
+//	  timeout, default to 5 minutes, see Freeze()
+//	POST /thaw: thaw the log file, see Thaw()
+//	POST /rotate: rotate the log file now, see Rotate()
+//
+// Mount it with http.StripPrefix, such as:
+//
+//	mux.Handle("/logging/", http.StripPrefix("/logging", logging.AdminHandler()))
+func AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_ = DumpState(w)
+	})
+	mux.HandleFunc("/health", HealthHandler)
+	mux.HandleFunc("/verify", verifyHandler)
+	mux.Handle("/files/", http.StripPrefix("/files", FilesHandler()))
+	mux.HandleFunc("/freeze", freezeHandler)
+	mux.HandleFunc("/thaw", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := Thaw(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("/rotate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := Rotate(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	return mux
+}
+
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	var rate int64
+	if s := r.URL.Query().Get("rate"); s != "" {
+		var err error
+		if rate, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "invalid rate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := VerifyArchives(r.Context(), rate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type result struct {
+		Path     string        `json:"path"`
+		Status   VerifyStatus  `json:"status"`
+		Size     int64         `json:"size"`
+		Duration time.Duration `json:"duration_ns"`
+		Error    string        `json:"error,omitempty"`
+	}
+	body := []result{}
+	for _, item := range results {
+		body = append(body, result{item.Path, item.Status, item.Size, item.Duration, errorString(item.Err)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// default auto thaw timeout of /freeze
+const defaultFreezeTimeout = 5 * time.Minute
+
+func freezeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	timeout := defaultFreezeTimeout
+	if s := r.URL.Query().Get("timeout"); s != "" {
+		var err error
+		if timeout, err = time.ParseDuration(s); err != nil {
+			http.Error(w, "invalid timeout: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	fw, _ := defaultWriters()
+	if fw == nil {
+		http.Error(w, ErrNoFileLogWriter.Error(), http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if err := fw.Freeze(ctx); err != nil {
+		cancel()
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	go func() {
+		fw.waitThawed()
+		cancel()
+	}()
+}
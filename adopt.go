@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// WithAdoptPattern makes file log writer adopt backups created by other
+// tools, such as lumberjack. Pattern is a regular expression matches base
+// name of the backup file, the sub match named "ts", or the first sub match,
+// is the timestamp parsed by layout in loc, local time if loc is nil. Such as
+// lumberjack backups in UTC:
+//
+//	WithAdoptPattern(`^app-(.+)\.log(\.gz)?$`, "2006-01-02T15-04-05.000", time.UTC)
+//
+// Adopted backups are listed, ordered and deleted by retention with native
+// backups, but never renamed or compressed. Can be used more than once.
+// Panics if pattern is invalid.
+func WithAdoptPattern(pattern, layout string, loc *time.Location) Option {
+	re := regexp.MustCompile(pattern)
+	if loc == nil {
+		loc = time.Local
+	}
+	return func(o *writerOptions) {
+		o.adopt = append(o.adopt, adoptPattern{re, layout, loc})
+	}
+}
+
+type adoptPattern struct {
+	re     *regexp.Regexp
+	layout string
+	loc    *time.Location
+}
+
+// Parse timestamp of backup file name, false if not match.
+func (p adoptPattern) parse(name string) (time.Time, bool) {
+	m := p.re.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	i := p.re.SubexpIndex("ts")
+	if i < 0 {
+		i = 1
+	}
+	if i >= len(m) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(p.layout, m[i], p.loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Returns timestamp of adopted backup file, false if not adopted.
+func (w *fileLogWriter) adoptedTime(backup string) (time.Time, bool) {
+	name := filepath.Base(backup)
+	for _, p := range w.adopt {
+		if t, ok := p.parse(name); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns adopted backups of the log file, compressed or not.
+func (w *fileLogWriter) getAdoptedFiles(logfilename string, compressed bool) ([]string, error) {
+	if len(w.adopt) == 0 {
+		return nil, nil
+	}
+
+	dir := filepath.Dir(logfilename)
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || name == filepath.Base(logfilename) || isSidecar(name) ||
+			(w.archiveSuffix(name) != "") != compressed {
+			continue
+		}
+		if _, ok := w.adoptedTime(name); ok {
+			r = append(r, filepath.Join(dir, name))
+		}
+	}
+	return r, nil
+}
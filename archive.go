@@ -0,0 +1,238 @@
+package logging
+
+import (
+	"archive/tar"
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// ArchiveInfo describes a rotated log file.
+type ArchiveInfo struct {
+	Path       string
+	RotatedAt  time.Time // parsed from file name, fallback to modification time
+	Size       int64
+	Compressed bool
+	Encrypted  bool
+	Checksum   string // sha256 hex string recorded by audit log, empty if not recorded
+	Bundle     bool   // daily bundle of archives, see WithDailyBundles()
+}
+
+// ListArchives returns rotated files of the log file, compressed or not,
+// newest first.
+func (w *fileLogWriter) ListArchives() ([]ArchiveInfo, error) {
+	return w.listArchives()
+}
+
+// ListArchives returns rotated files of log file at path, newest first. Match
+// files the same way as file log writer retention.
+func ListArchives(path string) ([]ArchiveInfo, error) {
+	return lookupFileWriter(path).listArchives()
+}
+
+// BackupInfo describes a backup of log file, compressed or not, returned by
+// ListBackups().
+type BackupInfo = ArchiveInfo
+
+// ListBackups returns backups of the log file, oldest first, see
+// ListBackups().
+func (w *fileLogWriter) ListBackups() ([]BackupInfo, error) {
+	return listBackups(w)
+}
+
+// ListBackups returns backups of log file at logPath, compressed or not,
+// oldest first, the same files counted by retention, in archive dir if
+// configured. Options of alive file log writer of logPath used, such as
+// WithArchiveDir() and backup naming, default options if not found.
+func ListBackups(logPath string) ([]BackupInfo, error) {
+	return listBackups(lookupFileWriter(logPath))
+}
+
+func listBackups(w *fileLogWriter) ([]BackupInfo, error) {
+	r, err := w.listArchives()
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return r, nil
+}
+
+// Returns alive file log writer of path, so options such as adopt patterns
+// are used, or a file log writer not opened if not found.
+func lookupFileWriter(path string) *fileLogWriter {
+	if w := findFileWriter(path); w != nil {
+		return w
+	}
+	if hasPathPlaceholders(path) {
+		return &fileLogWriter{path: resolvePathTemplate(path, hal.Now()), pathTemplate: path}
+	}
+	return &fileLogWriter{path: path}
+}
+
+// Returns alive file log writer of path, nil if not found.
+func findFileWriter(path string) *fileLogWriter {
+	for _, w := range registeredWriters() {
+		if fw, ok := w.(*fileLogWriter); ok && (fw.CurrentPath() == path || fw.pathTemplate != "" && fw.pathTemplate == path) {
+			return fw
+		}
+	}
+	return nil
+}
+
+// Returns alive file log writer of path, for operations must follow its
+// options, ErrNoFileLogWriter if not found.
+func registeredFileWriter(path string) (*fileLogWriter, error) {
+	if w := findFileWriter(path); w != nil {
+		return w, nil
+	}
+	return nil, fmt.Errorf("%w of %s", ErrNoFileLogWriter, path)
+}
+
+func (w *fileLogWriter) listArchives() ([]ArchiveInfo, error) {
+	var r []ArchiveInfo
+	for _, path := range w.datedPaths() {
+		archives, err := w.listArchivesOf(path)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, archives...)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return w.olderBackup(w.CurrentPath(), r[j].Path, r[j].RotatedAt, r[i].Path, r[i].RotatedAt)
+	})
+	return r, nil
+}
+
+// Returns archives of log file at path, see listArchives().
+func (w *fileLogWriter) listArchivesOf(path string) ([]ArchiveInfo, error) {
+	compressed, err := w.getCompressedFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := w.getUncompressedFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	adopted, err := w.getAdoptedFiles(path, false)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed = append(uncompressed, adopted...)
+
+	checksums := w.archiveChecksums(path)
+	r := make([]ArchiveInfo, 0, len(compressed)+len(uncompressed))
+	for _, f := range append(compressed, uncompressed...) {
+		info, err := os.Stat(f)
+		if err != nil {
+			// deleted by retention
+			continue
+		}
+		r = append(r, ArchiveInfo{
+			Path:       f,
+			RotatedAt:  w.backupTime(path, f),
+			Size:       info.Size(),
+			Compressed: w.archiveSuffix(f) != "",
+			Encrypted:  strings.HasSuffix(f, encSuffix),
+			Checksum:   checksums[f],
+		})
+	}
+
+	bundles, err := w.getBundles(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range bundles {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		day, _ := w.bundleDay(path, f)
+		r = append(r, ArchiveInfo{
+			Path:       f,
+			RotatedAt:  day.AddDate(0, 0, 1).Add(-time.Nanosecond),
+			Size:       info.Size(),
+			Compressed: true,
+			Bundle:     true,
+			Checksum:   checksums[f],
+		})
+	}
+
+	return r, nil
+}
+
+// OpenArchive opens an archive for read, decompress if it is gzip or zstd file,
+// detected by content, not file extension. Returns ErrArchiveEncrypted if the
+// archive encrypted, use OpenArchiveKey() instead.
+func OpenArchive(path string) (io.ReadCloser, error) {
+	return OpenArchiveKey(path, nil)
+}
+
+// OpenArchiveKey opens an archive like OpenArchive(), decrypt by key if
+// encrypted, see WithEncryption().
+func OpenArchiveKey(path string, key KeyFunc) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := openArchiveReader(bufio.NewReader(f), key)
+	if err != nil {
+		safeClose("archive", f)
+		return nil, err
+	}
+	br := bufio.NewReader(dec)
+	if isTar(br) {
+		// daily bundle, see WithDailyBundles()
+		bundle := &bundleReader{tr: tar.NewReader(br), key: key, dec: dec}
+		return &archiveReader{bundle, f, bundle}, nil
+	}
+	return &archiveReader{br, f, dec}, nil
+}
+
+// Returns reader of decrypted and decompressed content of br.
+func openArchiveReader(br *bufio.Reader, key KeyFunc) (io.ReadCloser, error) {
+	if isEncrypted(br) {
+		dr, err := newDecryptReader(br, key)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(dr)
+	}
+	c, err := sniffCodec(br)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return ioutil.NopCloser(br), nil
+	}
+	return c.newReader(br)
+}
+
+type archiveReader struct {
+	io.Reader
+	f   *os.File
+	dec io.ReadCloser
+}
+
+func (r *archiveReader) Close() error {
+	if err := r.dec.Close(); err != nil {
+		safeClose("archive", r.f)
+		return err
+	}
+	return r.f.Close()
+}
+
+// OpenCurrent opens the live log file for read, by a separate file handle,
+// not affects the writer.
+func (w *fileLogWriter) OpenCurrent() (io.ReadCloser, error) {
+	return os.Open(w.CurrentPath())
+}
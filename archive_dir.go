@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// WithArchiveDir makes file log writer move backups to dir on rotation,
+// compressed, cleaned by retention and recovered on start there, instead of
+// next to the live log file, such as archives on a large slow volume. Dir
+// created if not exist, with permission of WithFileMode() plus search bits,
+// and owner of WithFileOwner(). Backups copied if dir on another device.
+//
+// Backups left next to the live log file before the option applied are not
+// managed anymore.
+func WithArchiveDir(dir string) Option {
+	return func(o *writerOptions) {
+		o.archiveDir = dir
+	}
+}
+
+// Returns path of logfilename as if it in archive dir, backup names derived
+// from it.
+func (w *fileLogWriter) archivePath(logfilename string) string {
+	if w.archiveDir == "" {
+		return logfilename
+	}
+	return filepath.Join(w.archiveDir, filepath.Base(logfilename))
+}
+
+// Create archive dir if not exist.
+func (w *fileLogWriter) ensureArchiveDir() error {
+	if _, err := os.Stat(w.archiveDir); !os.IsNotExist(err) {
+		return err
+	}
+
+	mode := w.createMode()
+	// directory searchable by who can read files
+	mode |= (mode & 0444) >> 2
+	if err := os.MkdirAll(w.archiveDir, mode); err != nil {
+		return err
+	}
+	if w.uid >= 0 || w.gid >= 0 {
+		if err := os.Chown(w.archiveDir, w.uid, w.gid); err != nil {
+			w.reportError("file", err)
+		}
+	}
+	return nil
+}
+
+// Rename the rotated log file to backup, copy then delete if backup on
+// another device.
+func moveBackup(src, dst string) error {
+	err := renameFunc(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if debugEnabled() {
+		debugf("rotate: %s and %s on different devices, copy then delete", src, dst)
+	}
+	if err = copyFile(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	syncDir(filepath.Dir(dst))
+	return os.Remove(src)
+}
+
+// Renames backup file, replaced to simulate cross device rename.
+var renameFunc = os.Rename
+
+// buffer size of copyFile, backups may be hundreds of MB
+const copyBufferSize = 256 * 1024
+
+// Copy src to dst and fsync, permission of src preserved.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer safeClose("file", in)
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.CopyBuffer(out, in, make([]byte, copyBufferSize)); err != nil {
+		safeClose("file", out)
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		safeClose("file", out)
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// Replace renameFunc failing by errno, restored on cleanup.
+func failRename(t *testing.T, errno syscall.Errno) {
+	t.Helper()
+	renameFunc = func(src, dst string) error {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: errno}
+	}
+	t.Cleanup(func() { renameFunc = os.Rename })
+}
+
+func TestMoveBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		errno   syscall.Errno
+		wantErr bool
+	}{
+		{"cross device", syscall.EXDEV, false},
+		{"other error", syscall.EACCES, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.1")
+			// larger than copy buffer, copied in chunks
+			content := bytes.Repeat([]byte("0123456789abcdef"), 3*copyBufferSize/16+7)
+			if err := ioutil.WriteFile(src, content, 0640); err != nil {
+				t.Fatal(err)
+			}
+			failRename(t, tt.errno)
+
+			err := moveBackup(src, dst)
+			if tt.wantErr {
+				if !errors.Is(err, tt.errno) {
+					t.Errorf("error %v, want %v", err, tt.errno)
+				}
+				if _, err := os.Stat(src); err != nil {
+					t.Errorf("source removed: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(dst)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("copied %d bytes, want %d, %v", len(got), len(content), err)
+			}
+			if info, err := os.Stat(dst); err != nil || info.Mode().Perm() != 0640 {
+				t.Errorf("copy mode %v, %v", info.Mode(), err)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("source not removed: %v", err)
+			}
+		})
+	}
+}
+
+// Archive dir on another device, backups copied then compressed there.
+func TestArchiveDirCrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, "archive")
+	failRename(t, syscall.EXDEV)
+	w := openTestFileWriter(t, filepath.Join(dir, "app.log"), 0, 0, WithArchiveDir(archiveDir))
+	rotateTimes(t, w, 2)
+
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 2 {
+		t.Fatalf("archives %v, want 2", archives)
+	}
+	for _, a := range archives {
+		if filepath.Dir(a.Path) != archiveDir || !a.Compressed {
+			t.Errorf("archive %+v, want compressed in %s", a, archiveDir)
+		}
+	}
+	left, err := filepath.Glob(filepath.Join(dir, "app*"))
+	if err != nil || len(left) != 1 {
+		t.Errorf("files next to live log file %v, %v", left, err)
+	}
+}
@@ -3,6 +3,7 @@ package logging
 import (
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
 
 	"github.com/redforks/life"
@@ -18,24 +19,54 @@ import (
 // generate a log like: `Too many logs, xxx logs lost'. So the memory won't
 // fill up with log messages.
 type asyncLogWriter struct {
+	writerCounters
+
 	w      io.Writer
-	ch     chan []byte
+	ch     chan asyncRecord
 	failed int32 // -1: disabled because internal writer error, > 0 how many writes lost.
 	closed int32 // 1 if `ch' chan closed
 
+	// read locked by senders to ch, locked by Close to close ch, no send
+	// on closed ch.
+	sendL sync.RWMutex
+
+	syncOnError bool
+	wal         *writeAheadLog // nil if write-ahead disabled
+	walL        sync.Mutex     // serializes producers append to wal then queue
+
 	exitCh chan struct{} // closed when write goroutine exit
+
+	failure failureState
+}
+
+type asyncRecord struct {
+	buf    []byte
+	walEnd int64 // logical end offset in write-ahead file, 0 if not in it
+
+	// not nil if the record should synced to disk, closed after synced.
+	synced chan struct{}
 }
 
 // NewAsyncLogWriter create a new instance of AsyncLogWriter, wrap an internal log writer.
-func NewAsyncLogWriter(w io.Writer) io.WriteCloser {
+func NewAsyncLogWriter(w io.Writer, opts ...Option) io.WriteCloser {
 	// queue at most 500 write request, more will dropped
-	r := &asyncLogWriter{w: w, ch: make(chan []byte, 500), exitCh: make(chan struct{})}
+	r := &asyncLogWriter{w: w, ch: make(chan asyncRecord, 500), exitCh: make(chan struct{})}
+	o := newWriterOptions(opts)
+	r.syncOnError = o.syncOnError
+	r.id = writerID(o, "async", r.innerPath())
+	if o.walPath != "" {
+		r.openWriteAhead(o.walPath, o.walMaxBytes)
+	}
 	go r.run()
+	registerWriter(r, o)
 	if !reset.TestMode() {
 		life.Register("asyncLogWriter", nil, func() {
 			_ = r.Close()
 		})
 		life.RegisterHook("CloseAsyncLogWriter", 0, life.OnAbort, func() {
+			if fw, ok := w.(*fileLogWriter); ok {
+				fw.markAborting()
+			}
 			_ = r.Close()
 		})
 	}
@@ -54,9 +85,13 @@ func (w *asyncLogWriter) Write(p []byte) (n int, err error) {
 	n = len(p)
 	buf := make([]byte, len(p))
 	copy(buf, p)
+	if w.syncOnError && ParseLevel(p) >= LevelError {
+		w.writeSynced(buf)
+		return
+	}
 	if failed := atomic.LoadInt32(&w.failed); failed != -1 {
-		select {
-		case w.ch <- buf:
+		if w.enqueue(buf) {
+			w.queued(len(w.ch))
 			if failed > 0 {
 				for !atomic.CompareAndSwapInt32(&w.failed, failed, 0) {
 					failed := atomic.LoadInt32(&w.failed)
@@ -68,37 +103,158 @@ func (w *asyncLogWriter) Write(p []byte) (n int, err error) {
 					w.handleInnerWriteError(err)
 				}
 			}
-		default:
+		} else {
 			atomic.AddInt32(&w.failed, 1)
+			w.drop(1)
 		}
+	} else {
+		w.drop(1)
 	}
 	return
 }
 
+// Queue the record if queue not full, append to write-ahead file first if
+// enabled.
+func (w *asyncLogWriter) enqueue(buf []byte) bool {
+	w.sendL.RLock()
+	defer w.sendL.RUnlock()
+	if atomic.LoadInt32(&w.closed) == 1 {
+		// closed after checked by Write
+		if _, err := w.w.Write(buf); err != nil {
+			w.reportError("async", err)
+		}
+		return true
+	}
+
+	if w.wal == nil {
+		select {
+		case w.ch <- asyncRecord{buf: buf}:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// lock keeps records order in write-ahead file same as in queue, and
+	// no other producer fills the queue after checked. Not wal.l, consumer
+	// takes it to mark records consumed.
+	w.walL.Lock()
+	defer w.walL.Unlock()
+	if len(w.ch) == cap(w.ch) {
+		return false
+	}
+	end, err := w.wal.append(buf)
+	if err != nil {
+		w.reportError("wal", err)
+	}
+	// blocks if filled by synced writes, drained by consumer
+	w.ch <- asyncRecord{buf: buf, walEnd: end}
+	return true
+}
+
+// Replay records left by last run to inner writer, then enable write-ahead.
+func (w *asyncLogWriter) openWriteAhead(path string, maxBytes int64) {
+	wal, err := openWriteAheadLog(path, maxBytes)
+	if err != nil {
+		w.reportError("wal", err)
+		return
+	}
+	records, err := wal.replay(w.w)
+	if err != nil {
+		w.reportError("wal", err)
+	}
+	if records > 0 && debugEnabled() {
+		debugf("wal: replayed %d records from %s", records, path)
+	}
+	w.wal = wal
+}
+
+// Queue the record even if the queue full, and wait until the record synced
+// to disk by inner writer.
+func (w *asyncLogWriter) writeSynced(buf []byte) {
+	if atomic.LoadInt32(&w.failed) == -1 {
+		w.drop(1)
+		return
+	}
+
+	rec := asyncRecord{buf: buf, synced: make(chan struct{})}
+	w.sendL.RLock()
+	if atomic.LoadInt32(&w.closed) == 1 {
+		w.sendL.RUnlock()
+		if err := writeAndSync(w.w, buf); err != nil {
+			w.reportError("async", err)
+		}
+		return
+	}
+	select {
+	case w.ch <- rec:
+		w.sendL.RUnlock()
+		w.queued(len(w.ch))
+	case <-w.exitCh:
+		w.sendL.RUnlock()
+		w.drop(1)
+		return
+	}
+
+	select {
+	case <-rec.synced:
+	case <-w.exitCh:
+	}
+}
+
 func (w *asyncLogWriter) handleInnerWriteError(err error) {
+	atomic.AddInt64(&w.innerErrors, 1)
+	w.failure.set(err)
+	w.reportError("async", err)
 	atomic.StoreInt32(&w.failed, -1)
 	w.drain()
 }
 
-// Close and flush asyncLogWriter buffer, latter .Write() request deliver to
-// inner writer directly.
+// Close and flush asyncLogWriter buffer, then close inner writer if it is
+// io.Closer. Later .Write() requests passed to the closed inner writer,
+// return its error, such as ErrWriterClosed of file log writer. Calling Close
+// more than once is no-op.
 func (w *asyncLogWriter) Close() error {
-	if atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
-		close(w.ch)
-		<-w.exitCh
+	// waits senders blocked on full queue, the consumer drains it
+	w.sendL.Lock()
+	if !atomic.CompareAndSwapInt32(&w.closed, 0, 1) {
+		w.sendL.Unlock()
+		return nil
+	}
+	close(w.ch)
+	w.sendL.Unlock()
+
+	<-w.exitCh
+	unregisterWriter(w)
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
 	}
 	return nil
 }
 
 // Goroutine function for internal writer.
 func (w *asyncLogWriter) run() {
-	for buf := range w.ch {
-		if _, err := w.w.Write(buf); err != nil {
+	for rec := range w.ch {
+		var err error
+		if rec.synced != nil {
+			err = writeAndSync(w.w, rec.buf)
+			close(rec.synced)
+		} else {
+			_, err = w.w.Write(rec.buf)
+		}
+		if err != nil {
 			w.handleInnerWriteError(err)
 			break
 		}
+		atomic.AddInt64(&w.written, int64(len(rec.buf)))
+		if rec.walEnd != 0 {
+			w.wal.consume(rec.walEnd)
+		}
 	}
 
+	if w.wal != nil {
+		w.wal.close()
+	}
 	close(w.exitCh)
 }
 
@@ -112,3 +268,32 @@ func (w *asyncLogWriter) drain() {
 		}
 	}
 }
+
+func (w *asyncLogWriter) stats() WriterStats {
+	r := w.snapshot("async", w.innerPath())
+	r.QueueDepth, r.QueueCap = len(w.ch), cap(w.ch)
+	return r
+}
+
+func (w *asyncLogWriter) innerPath() string {
+	if inner, ok := w.w.(registeredWriter); ok {
+		return inner.stats().Path
+	}
+	return ""
+}
+
+func (w *asyncLogWriter) workerStatus() string {
+	running := true
+	select {
+	case <-w.exitCh:
+		running = false
+	default:
+	}
+	return fmt.Sprintf("consumer running: %v, failed: %d, closed: %v",
+		running, atomic.LoadInt32(&w.failed), atomic.LoadInt32(&w.closed) == 1)
+}
+
+// async writer disabled after inner writer failed, all logs lost.
+func (w *asyncLogWriter) health() *Problem {
+	return w.failure.problem(&w.writerCounters, "async", w.innerPath(), Broken, 0)
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redforks/testing/reset"
+)
+
+// Returns an async writer of inner, closed on cleanup, not registered to
+// life.
+func newTestAsyncWriter(t *testing.T, inner *memWriter, opts ...Option) *asyncLogWriter {
+	t.Helper()
+	reset.Enable()
+	w := NewAsyncLogWriter(inner, append([]Option{WithoutRegistry()}, opts...)...).(*asyncLogWriter)
+	t.Cleanup(func() {
+		_ = w.Close()
+		reset.Disable()
+	})
+	return w
+}
+
+// Inner writer keeps written records in memory, writes blocked until gate
+// closed if not nil.
+type memWriter struct {
+	l    sync.Mutex
+	buf  bytes.Buffer
+	gate chan struct{}
+}
+
+func (m *memWriter) Write(p []byte) (int, error) {
+	if m.gate != nil {
+		<-m.gate
+	}
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.buf.Write(p)
+}
+
+func (m *memWriter) String() string {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.buf.String()
+}
+
+// Synced write blocked on full queue while Close, written before Close
+// returns, not panic by send on closed queue.
+func TestAsyncSyncedWriteWhileClose(t *testing.T) {
+	inner := &memWriter{gate: make(chan struct{})}
+	w := newTestAsyncWriter(t, inner, WithSyncOnError())
+	// consumer blocked on the first record, the rest fill the queue
+	for i := 0; i <= cap(w.ch); i++ {
+		if _, err := w.Write([]byte("[INFO] fill\n")); err != nil {
+			t.Fatal(err)
+		}
+		for i == 0 && len(w.ch) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := w.Write([]byte("[ERROR] failed\n")); err != nil {
+			t.Error(err)
+		}
+	}()
+	closed := make(chan error)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		closed <- w.Close()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(inner.gate)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	if !strings.Contains(inner.String(), "[ERROR] failed\n") {
+		t.Error("synced record lost")
+	}
+}
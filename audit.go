@@ -0,0 +1,192 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+const (
+	auditSuffix = `.audit`
+
+	defaultAuditMaxSize = 10 * 1024 * 1024
+)
+
+// WithAuditLog enables audit log of file log writer, each rotation,
+// compression, retention deletion and recovery action appended to
+// "<logfile>.audit" as a json line. If audit file exceeds maxSize, oldest
+// entries truncated, default to 10MB if maxSize <= 0.
+func WithAuditLog(maxSize int64) Option {
+	return func(o *writerOptions) {
+		if maxSize <= 0 {
+			maxSize = defaultAuditMaxSize
+		}
+		o.auditMaxSize = maxSize
+	}
+}
+
+// Audit actions.
+const (
+	auditRotate   = "rotate"
+	auditCompress = "compress"
+	auditDelete   = "delete"
+	auditRecover  = "recover"
+	auditEncrypt  = "encrypt"
+	auditRename   = "rename"
+	auditBundle   = "bundle"
+)
+
+// Retention deletion reasons.
+const (
+	reasonCount = "count"
+	reasonAge   = "age"
+	reasonSize  = "size"
+	reasonPurge = "purge"
+	reasonSpace = "space"
+)
+
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	File       string    `json:"file"`
+	Target     string    `json:"target,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	SizeBefore int64     `json:"size_before,omitempty"`
+	SizeAfter  int64     `json:"size_after,omitempty"`
+	Checksum   string    `json:"sha256,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Append only json lines audit file, nil *auditLog is valid and records
+// nothing.
+type auditLog struct {
+	l       sync.Mutex
+	writer  string // ID of the writer
+	path    string
+	maxSize int64
+}
+
+func newAuditLog(writer, logfile string, maxSize int64) *auditLog {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &auditLog{writer: writer, path: logfile + auditSuffix, maxSize: maxSize}
+}
+
+func (a *auditLog) record(e auditEntry) {
+	if a == nil {
+		return
+	}
+	if err := a.append(e); err != nil {
+		reportError("audit", &WriterError{a.writer, err})
+	}
+}
+
+func (a *auditLog) append(e auditEntry) error {
+	e.Time = hal.Now()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	if info, err := os.Stat(a.path); err == nil && info.Size()+int64(len(line)) > a.maxSize {
+		if err := a.truncate(int64(len(line))); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer safeClose("audit", f)
+	_, err = f.Write(line)
+	return err
+}
+
+// Drop oldest entries, keep about half of maxSize, leave room for n bytes.
+// Rewrite audit file by temp file and rename.
+func (a *auditLog) truncate(n int64) error {
+	content, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	keep := a.maxSize/2 - n
+	for int64(len(content)) > keep && len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			content = nil
+			break
+		}
+		content = content[i+1:]
+	}
+
+	tmp := a.path + `.tmp`
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// Returns sha256 hex string of file, empty if failed.
+func fileChecksum(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer safeClose("audit", f)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Returns archive path to checksum map recorded in audit file of logfile,
+// empty if no audit file.
+func auditChecksums(logfile string) map[string]string {
+	r := map[string]string{}
+	content, err := ioutil.ReadFile(logfile + auditSuffix)
+	if err != nil {
+		return r
+	}
+
+	for _, line := range bytes.Split(content, []byte{'\n'}) {
+		var e auditEntry
+		if len(line) == 0 || json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		switch {
+		case (e.Action == auditCompress || e.Action == auditEncrypt) && e.Checksum != "":
+			r[e.Target] = e.Checksum
+		case e.Action == auditRename:
+			// numbered backups renamed on rotation
+			if sum, ok := r[e.File]; ok {
+				r[e.Target] = sum
+				delete(r, e.File)
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCloseTimeout = 10 * time.Second
+
+// WithCloseTimeout set max time Close of file log writer waits for
+// background compression and retention to finish, default to 10 seconds.
+// Zero not wait, unfinished work resumed on next start.
+func WithCloseTimeout(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.closeTimeout = d
+	}
+}
+
+// Background work of file log writer, run in order by one worker goroutine.
+type backgroundJob struct {
+	// pending job of the same non-empty key superseded by later one, such as
+	// retention
+	key string
+	run func()
+}
+
+// Queue fn to run after queued jobs, start the worker goroutine if not
+// running. If key not empty, pending job of the same key removed, so
+// redundant retention jobs of a rotation storm coalesced, compressions never
+// dropped.
+func (w *fileLogWriter) enqueueJob(key string, fn func()) {
+	w.jobsL.Lock()
+	defer w.jobsL.Unlock()
+
+	if key != "" {
+		for i, job := range w.jobs {
+			if job.key == key {
+				w.jobs = append(w.jobs[:i], w.jobs[i+1:]...)
+				atomic.AddInt64(&w.background, -1)
+				break
+			}
+		}
+	}
+	w.jobs = append(w.jobs, backgroundJob{key, fn})
+	atomic.AddInt64(&w.background, 1)
+	if !w.jobsRunning {
+		w.jobsRunning = true
+		w.backgroundWG.Add(1)
+		go w.runJobs()
+	}
+}
+
+// Queue retention of logfilename, see enqueueJob().
+func (w *fileLogWriter) enqueueCleanup(logfilename string) {
+	w.enqueueJob("cleanup:"+logfilename, func() {
+		w.waitThawed()
+		if w.bundleAge > 0 {
+			if err := w.bundleOldArchives(); err != nil {
+				w.reportError("bundle", err)
+			}
+		}
+		if err := w.cleanOldBackupFiles(logfilename); err != nil {
+			w.reportError("retention", err)
+		}
+		w.reconcileManifest()
+		if w.pathTemplate != "" {
+			w.removeEmptyDatedDirs()
+		}
+	})
+}
+
+// Run fn by the background worker after queued jobs, wait it done. Run fn
+// directly if called by the worker, such as from OnRotate callback, would
+// wait itself forever otherwise.
+func (w *fileLogWriter) runByWorker(fn func()) {
+	if w.onWorker() {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	w.enqueueJob("", func() {
+		defer close(done)
+		fn()
+	})
+	<-done
+}
+
+// Returns true if called by the worker goroutine.
+func (w *fileLogWriter) onWorker() bool {
+	id := atomic.LoadUint64(&w.workerID)
+	return id != 0 && id == goroutineID()
+}
+
+// Worker goroutine, exit if no job queued.
+func (w *fileLogWriter) runJobs() {
+	defer w.backgroundWG.Done()
+
+	atomic.StoreUint64(&w.workerID, goroutineID())
+	for {
+		w.jobsL.Lock()
+		if len(w.jobs) == 0 {
+			atomic.StoreUint64(&w.workerID, 0)
+			w.jobsRunning = false
+			w.jobsL.Unlock()
+			return
+		}
+		job := w.jobs[0]
+		w.jobs = w.jobs[1:]
+		w.jobsL.Unlock()
+
+		job.run()
+		atomic.AddInt64(&w.background, -1)
+	}
+}
+
+// Wait background jobs at most closeTimeout. Must not hold w.l.
+func (w *fileLogWriter) waitBackground() {
+	if w.closeTimeout <= 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.backgroundWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(w.closeTimeout):
+		w.reportError("file", fmt.Errorf("close: background work not finished in %s, %d queued",
+			w.closeTimeout, atomic.LoadInt64(&w.background)))
+	}
+}
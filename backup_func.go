@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupNameFunc returns path of the backup of a log file rotated at t, base
+// is path of the log file without extension, in archive directory if set,
+// ext is extension of the log file, such as `.log`. See
+// WithBackupNameFunc().
+type BackupNameFunc func(base, ext string, t time.Time) string
+
+// WithBackupNameFunc makes file log writer name backups by name, instead of
+// backup time layout. IsBackup reports whether a file name, without directory
+// and compression, encryption suffixes, is a backup named by name, used by
+// retention and recovery to find backups. Backups must be in the directory of
+// base, ordered by modification time. If rotated twice to the same name, a
+// counter such as `-2` inserted before ext, isBackup should accept it.
+//
+// NewFileLogWriter returns error if a name returned by name in another
+// directory, or not accepted by isBackup, or isBackup accepts the log file.
+func WithBackupNameFunc(name BackupNameFunc, isBackup func(name string) bool) Option {
+	return func(o *writerOptions) {
+		o.backupName, o.isBackup = name, isBackup
+	}
+}
+
+// Returns error if backup names of log file path not matched by isBackup.
+func (w *fileLogWriter) validateBackupNameFunc(path string) error {
+	if w.isBackup == nil {
+		return errors.New("logging: backup name func without isBackup")
+	}
+	name := w.backupFilenameAt(path, time.Date(2001, 12, 23, 14, 35, 46, 0, time.Local))
+	if filepath.Dir(name) != filepath.Dir(w.archivePath(path)) {
+		return fmt.Errorf("logging: backup %q of %q not in directory %q", name, path, filepath.Dir(w.archivePath(path)))
+	}
+	if base := filepath.Base(name); !w.isBackup(base) || w.archiveSuffix(base) != "" {
+		return fmt.Errorf("logging: backup %q of %q not matched by isBackup", name, path)
+	}
+	if w.isBackup(filepath.Base(path)) {
+		return fmt.Errorf("logging: log file %q matched by isBackup", path)
+	}
+	return nil
+}
+
+// Returns files named by backup name func of the log file, such as backups
+// and their archives, match called with base name of the files.
+func (w *fileLogWriter) getNamedFiles(logfilename string, match func(name string) bool) ([]string, error) {
+	dir := filepath.Dir(w.archivePath(logfilename))
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var r []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || name == filepath.Base(logfilename) {
+			continue
+		}
+		if match(name) {
+			r = append(r, filepath.Join(dir, name))
+		}
+	}
+	return r, nil
+}
+
+// Returns backups named by backup name func with the extra suffix, such as
+// `.gz`.
+func (w *fileLogWriter) getNamedBackups(logfilename, suffix string) ([]string, error) {
+	return w.getNamedFiles(logfilename, func(name string) bool {
+		if isSidecar(name) || !strings.HasSuffix(name, suffix) || strings.HasSuffix(name, bundleSuffix) {
+			return false
+		}
+		name = strings.TrimSuffix(name, suffix)
+		return w.archiveSuffix(name) == "" && w.isBackup(name)
+	})
+}
+
+// Returns temp files of compression, encryption and signing of backups
+// named by backup name func.
+func (w *fileLogWriter) getNamedTemps(logfilename string) ([]string, error) {
+	return w.getNamedFiles(logfilename, func(name string) bool {
+		return strings.HasSuffix(name, `.tmp`) && w.isBackup(w.trimArchiveSuffix(strings.TrimSuffix(name, `.tmp`)))
+	})
+}
@@ -0,0 +1,234 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithBackupTimeLayout set time layout of backup file names, default to
+// `2006-01-02-150405`. By default time inserted before the extension, such
+// as `app-2024-05-01-150405.log`, if afterExt, appended to the log file name
+// after a dot, such as `app.log.20240501T150405` for layout
+// `20060102T150405`.
+//
+// Backups are sorted by the time parsed from their names, so the layout need
+// not be lexicographically sortable, but it must contain all fields to
+// second, and no path separator, NewFileLogWriter() returns error otherwise.
+func WithBackupTimeLayout(layout string, afterExt bool) Option {
+	return func(o *writerOptions) {
+		o.backupLayout, o.backupAfterExt = layout, afterExt
+	}
+}
+
+// WithBackupUTC makes backup file names use UTC time, suffixed by `Z`, such as
+// `app-2024-05-01-150405Z.log`, instead of local time, so backups sort right
+// across DST transitions, and names agree among hosts of different time
+// zones. Retention parses backup names the same way, backups named in local
+// time not recognized, and vice versa.
+func WithBackupUTC() Option {
+	return func(o *writerOptions) {
+		o.backupUTC = true
+	}
+}
+
+// WithBackupOrigin inserts hostname and/or pid before time of backup file
+// names, such as `app-host1-1234-2024-05-01-150405.log`, so backups collected
+// from many hosts not collide. Retention and recovery find backups of any pid
+// of this host, and backups named without origin. Hostname omitted if failed
+// to get it. Not apply to numbered backups.
+func WithBackupOrigin(host, pid bool) Option {
+	return func(o *writerOptions) {
+		o.backupHost, o.backupPID = host, pid
+	}
+}
+
+// Returns hostname inserted to backup file names, empty if failed to get it.
+func backupHostname() string {
+	name, err := os.Hostname()
+	if err != nil || strings.ContainsAny(name, `/\`) {
+		if debugEnabled() {
+			debugf("backup name: hostname %q omitted: %v", name, err)
+		}
+		return ""
+	}
+	return name
+}
+
+// Returns origin part of backup file names, such as `host1-1234-`, see
+// WithBackupOrigin().
+func (w *fileLogWriter) backupOrigin() string {
+	var r string
+	if w.backupHost != "" {
+		r += w.backupHost + `-`
+	}
+	if w.backupPID {
+		r += strconv.Itoa(os.Getpid()) + `-`
+	}
+	return r
+}
+
+// Returns error if layout can not parse back the time it formats.
+func validateBackupLayout(layout string) error {
+	if strings.ContainsAny(layout, `/\*?[`) {
+		return fmt.Errorf("logging: backup time layout %q contains path separator or glob meta character", layout)
+	}
+	ref := time.Date(2001, 12, 23, 14, 35, 46, 0, time.Local)
+	t, err := time.ParseInLocation(layout, ref.Format(layout), time.Local)
+	if err != nil || !t.Equal(ref) {
+		return fmt.Errorf("logging: backup time layout %q loses time fields, backups can not be sorted", layout)
+	}
+	return nil
+}
+
+// Returns backup file name parts before and after time.
+func (w *fileLogWriter) backupNameParts(logfilename string) (prefix, suffix string) {
+	logfilename = w.archivePath(logfilename)
+	if w.backupAfterExt {
+		return logfilename + `.`, ``
+	}
+	base, ext := w.splitLogFilename(logfilename)
+	return base + `-`, ext
+}
+
+// Returns glob pattern matches backups with the extra suffix, such as `.gz`.
+// Log file name escaped, suffix is a pattern.
+func (w *fileLogWriter) backupPattern(logfilename, suffix string) string {
+	prefix, ext := w.backupNameParts(logfilename)
+	return globEscape(prefix) + `*` + globEscape(ext) + suffix
+}
+
+// Escape glob meta characters of path, such as directory named `[prod]`.
+// Meta character quoted by a character class, because filepath.Match not
+// support backslash escaping on Windows.
+func globEscape(path string) string {
+	if !strings.ContainsAny(path, `*?[\`) {
+		return path
+	}
+
+	var b strings.Builder
+	for _, c := range path {
+		switch {
+		case c == '*' || c == '?' || c == '[':
+			b.WriteByte('[')
+			b.WriteRune(c)
+			b.WriteByte(']')
+		case c == '\\' && os.PathSeparator != '\\':
+			b.WriteString(`\\`)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Parse rotation time from native backup name, suffix such as `.gz`
+// trimmed.
+func (w *fileLogWriter) nativeBackupTime(logfilename, name string) (time.Time, bool) {
+	prefix, ext := w.backupNameParts(logfilename)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) || len(name) < len(prefix)+len(ext) {
+		return time.Time{}, false
+	}
+	ts := name[len(prefix) : len(name)-len(ext)]
+	if t, ok := w.parseBackupTime(ts); ok {
+		return t, true
+	}
+	if w.backupHost == "" && !w.backupPID {
+		return time.Time{}, false
+	}
+	return w.parseBackupTime(w.trimBackupOrigin(ts))
+}
+
+// Trim hostname and pid from time part of backup name, pid of any process
+// trimmed, see WithBackupOrigin().
+func (w *fileLogWriter) trimBackupOrigin(ts string) string {
+	if w.backupHost != "" {
+		ts = strings.TrimPrefix(ts, w.backupHost+`-`)
+	}
+	if w.backupPID {
+		if i := strings.IndexByte(ts, '-'); i > 0 && isDigits(ts[:i]) {
+			ts = ts[i+1:]
+		}
+	}
+	return ts
+}
+
+// Returns true if s is not empty and all ASCII digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Parse time part of backup name, may have a counter, see
+// uniqueBackupName().
+func (w *fileLogWriter) parseBackupTime(ts string) (time.Time, bool) {
+	t, err := time.ParseInLocation(w.timeLayout(), ts, w.backupLocation())
+	if err == nil {
+		return t, true
+	}
+
+	// counter of backups rotated in the same second, see uniqueBackupName()
+	i := strings.LastIndexByte(ts, '-')
+	if i < 0 {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(ts[i+1:])
+	if err != nil || n < 2 {
+		return time.Time{}, false
+	}
+	if t, err = time.ParseInLocation(w.timeLayout(), ts[:i], w.backupLocation()); err != nil {
+		return time.Time{}, false
+	}
+	// later backups of the same second sorted after
+	return t.Add(time.Duration(n)), true
+}
+
+// Returns bakFile if no backup of the name, such as compressed one, exists,
+// otherwise appends a counter to the time, such as
+// `app-2024-05-01-150405-2.log`, for rotated more than once in a second.
+func (w *fileLogWriter) uniqueBackupName(logfilename, bakFile string) string {
+	_, ext := w.backupNameParts(logfilename)
+	base := strings.TrimSuffix(bakFile, ext)
+	name := bakFile
+	for n := 2; backupExists(name); n++ {
+		name = base + `-` + strconv.Itoa(n) + ext
+	}
+	return name
+}
+
+// Returns true if the backup or its archive exists.
+func backupExists(bakFile string) bool {
+	if _, err := os.Lstat(bakFile); err == nil {
+		return true
+	}
+	matches, _ := filepath.Glob(globEscape(bakFile) + `.*`)
+	return len(matches) > 0
+}
+
+// Writers not created by NewFileLogWriter, such as by lookupFileWriter(),
+// use default layout.
+func (w *fileLogWriter) timeLayout() string {
+	layout := w.backupLayout
+	if layout == "" {
+		layout = backupTimeLayout
+	}
+	if w.backupUTC {
+		layout += `Z`
+	}
+	return layout
+}
+
+// Returns time zone of backup file names.
+func (w *fileLogWriter) backupLocation() *time.Location {
+	if w.backupUTC {
+		return time.UTC
+	}
+	return time.Local
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Rotated more than once in a second, each backup gets its own name, sorted
+// by rotation order.
+func TestRotateSameSecond(t *testing.T) {
+	now := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	freezeNow(t, now)
+	w := newTestFileWriter(t, 0, 0)
+	rotateTimes(t, w, 3)
+
+	// newest first
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(w.CurrentPath())
+	want := []string{"app-2024-05-01-150405-3.log.gz", "app-2024-05-01-150405-2.log.gz", "app-2024-05-01-150405.log.gz"}
+	if len(archives) != len(want) {
+		t.Fatalf("archives %v, want %v", archives, want)
+	}
+	for i, a := range archives {
+		if a.Path != filepath.Join(dir, want[i]) {
+			t.Errorf("archive %d %s, want %s", i, filepath.Base(a.Path), want[i])
+		}
+		// line i written before rotation i
+		if content, wantContent := readArchive(t, a.Path), fmt.Sprintf("line %d\n", len(want)-1-i); content != wantContent {
+			t.Errorf("%s content %q, want %q", a.Path, content, wantContent)
+		}
+	}
+}
+
+// Glob metacharacters in the dir and file name of the log file, backups
+// listed, recovered and cleaned, files matched by the unescaped pattern
+// untouched.
+func TestBackupGlobMeta(t *testing.T) {
+	tests := []struct {
+		dir, file, decoy string
+	}{
+		{"[prod]", "app.log", "p/app-2024-05-01-150000.log"},
+		{"a*b", "app.log", "aXb/app-2024-05-01-150000.log"},
+		{"x?y", "app[1].log", "xzy/app1-2024-05-01-150000.log"},
+		{"logs", "ap*p.log", "logs/apXp-2024-05-01-150000.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir+"/"+tt.file, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, tt.dir)
+			path := filepath.Join(dir, tt.file)
+			ext := filepath.Ext(tt.file)
+			leftover := strings.TrimSuffix(path, ext) + "-2024-05-01-150000" + ext
+			decoy := filepath.Join(root, tt.decoy)
+			for _, f := range []string{leftover, decoy} {
+				if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(f, []byte("left by last run\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			base := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+			freezeNow(t, base)
+			w := openTestFileWriter(t, path, 0, 3)
+			w.waitBackground()
+			if content := readArchive(t, leftover+".gz"); content != "left by last run\n" {
+				t.Errorf("recovered %q", content)
+			}
+			for i := 0; i < 3; i++ {
+				freezeNow(t, base.Add(time.Duration(i)*time.Second))
+				if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+				w.waitBackground()
+			}
+
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range archives {
+				if !a.Compressed {
+					t.Errorf("%s not compressed", a.Path)
+					continue
+				}
+				got = append(got, readArchive(t, a.Path))
+			}
+			if want := []string{"line 2\n", "line 1\n", "line 0\n"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("archives %q, want %q", got, want)
+			}
+			if content, err := ioutil.ReadFile(decoy); err != nil || string(content) != "left by last run\n" {
+				t.Errorf("decoy changed %q, %v", content, err)
+			}
+		})
+	}
+}
+
+// Backup names in local time or UTC, rotation time parsed back from names,
+// not deleted by age retention.
+func TestBackupUTC(t *testing.T) {
+	oldLocal := time.Local
+	time.Local = time.FixedZone("UTC+8", 8*3600)
+	defer func() { time.Local = oldLocal }()
+	now := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"local", nil, "app-2024-05-01-150405.log.gz"},
+		{"utc", []Option{WithBackupUTC()}, "app-2024-05-01-070405Z.log.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freezeNow(t, now)
+			w := newTestFileWriter(t, 0, 0, append(tt.opts, WithMaxAge(time.Hour))...)
+			rotateTimes(t, w, 1)
+
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) != 1 {
+				t.Fatalf("archives %v, want %s", archives, tt.want)
+			}
+			a := archives[0]
+			if name := filepath.Base(a.Path); name != tt.want {
+				t.Errorf("archive %s, want %s", name, tt.want)
+			}
+			if !a.RotatedAt.Equal(now) {
+				t.Errorf("rotated at %s, want %s", a.RotatedAt, now)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultBufferSize    = 64 * 1024
+	defaultFlushInterval = 200 * time.Millisecond
+)
+
+// WithBuffer makes file log writer buffer records in memory of size bytes,
+// so bursts of records coalesce into large writes. Buffer flushed if full,
+// every flushInterval, before fsync, rotation and Close. Zero size uses 64KB,
+// zero flushInterval uses 200ms. Records in buffer lost on crash, and not
+// visible to readers of the live log file until flushed.
+func WithBuffer(size int, flushInterval time.Duration) Option {
+	return func(o *writerOptions) {
+		if size <= 0 {
+			size = defaultBufferSize
+		}
+		if flushInterval <= 0 {
+			flushInterval = defaultFlushInterval
+		}
+		o.bufferSize, o.flushInterval = size, flushInterval
+	}
+}
+
+// Underlying writer of fileLogWriter.buf, writes to the live log file, or
+// stderr if disk full.
+type bufferTarget struct {
+	w *fileLogWriter
+}
+
+func (t bufferTarget) Write(p []byte) (int, error) {
+	n, err := t.w.writeFull(p)
+	if isNoSpace(err) {
+		return t.w.handleNoSpace(p, n, err)
+	}
+	if err == nil {
+		t.w.leaveStderrMode()
+	}
+	return n, err
+}
+
+// Write p to buf, must hold w.l.
+func (w *fileLogWriter) writeBuffered(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	if err != nil {
+		w.resetBuffer(err)
+	}
+	return n, err
+}
+
+// Flush buf to the live log file, no-op if not buffered. Must hold w.l.
+func (w *fileLogWriter) flushLocked() error {
+	if w.buf == nil || w.buf.Buffered() == 0 {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.resetBuffer(err)
+		return err
+	}
+	return nil
+}
+
+// bufio.Writer stops working after write error, drop buffered records to
+// recover. Must hold w.l.
+func (w *fileLogWriter) resetBuffer(err error) {
+	if n := w.buf.Buffered(); n > 0 {
+		w.reportError("file", fmt.Errorf("flush buffer of %s, %d bytes dropped: %w", w.CurrentPath(), n, err))
+	}
+	w.buf.Reset(bufferTarget{w})
+}
+
+// Flush buf every d until stop closed.
+func (w *fileLogWriter) runFlushTicker(d time.Duration, stop chan struct{}) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			w.flushPending()
+		}
+	}
+}
+
+// Flush buf if not empty. Holds w.l, so never flushes to a file closed by
+// rotation.
+func (w *fileLogWriter) flushPending() {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed || w.f == nil || w.frozen != nil {
+		return
+	}
+	// error reported by resetBuffer
+	_ = w.flushLocked()
+}
@@ -0,0 +1,223 @@
+package logging
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// BundleOptions controls ExportBundle.
+type BundleOptions struct {
+	// Max bytes of the live log file included, the tail of the file
+	// included if it is larger, default to 10MB.
+	MaxLiveSize int64
+
+	// Number of most recent archives included, default to 5, ignored if
+	// From or To set.
+	Archives int
+
+	// Include archives may contain logs written in the time range, zero value
+	// means not bounded.
+	From, To time.Time
+
+	// Include audit log.
+	Audit bool
+
+	// Matches of the patterns in the live log file replaced by "[REDACTED]".
+	Redact []*regexp.Regexp
+}
+
+const defaultBundleLiveSize = 10 * 1024 * 1024
+
+// ExportBundle writes a tar.gz of the live log file, recent archives, and
+// "manifest.json" contains statistics and state dump, to out. Logging not
+// paused.
+func (w *fileLogWriter) ExportBundle(out io.Writer, opts BundleOptions) error {
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	files, err := w.bundleArchives(opts)
+	if err != nil {
+		return err
+	}
+
+	var included []string
+	for _, f := range files {
+		ok, err := addBundleFile(tw, f)
+		if err != nil {
+			return err
+		}
+		if ok {
+			included = append(included, filepath.Base(f))
+		}
+	}
+
+	live, err := w.bundleLive(opts)
+	if err != nil {
+		return err
+	}
+	if err = addBundleContent(tw, filepath.Base(w.CurrentPath()), live); err != nil {
+		return err
+	}
+	included = append(included, filepath.Base(w.CurrentPath()))
+
+	if opts.Audit {
+		ok, err := addBundleFile(tw, w.CurrentPath()+auditSuffix)
+		if err != nil {
+			return err
+		}
+		if ok {
+			included = append(included, filepath.Base(w.CurrentPath()+auditSuffix))
+		}
+	}
+
+	manifest, err := w.bundleManifest(included)
+	if err != nil {
+		return err
+	}
+	if err = addBundleContent(tw, "manifest.json", manifest); err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// ExportBundle exports bundle of the log file created by config, or the first
+// alive file log writer, see ExportBundle method of file log writer.
+func ExportBundle(w io.Writer, opts BundleOptions) error {
+	fw, _ := defaultWriters()
+	if fw == nil {
+		return ErrNoFileLogWriter
+	}
+	return fw.ExportBundle(w, opts)
+}
+
+func (w *fileLogWriter) bundleArchives(opts BundleOptions) ([]string, error) {
+	if !opts.From.IsZero() || !opts.To.IsZero() {
+		to := opts.To
+		if to.IsZero() {
+			to = time.Unix(1<<62, 0)
+		}
+		files, err := rangeFiles(w.CurrentPath(), opts.From, to)
+		if err != nil {
+			return nil, err
+		}
+		// the live log file always included
+		return files[:len(files)-1], nil
+	}
+
+	n := opts.Archives
+	if n <= 0 {
+		n = 5
+	}
+	archives, err := w.listArchives()
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) > n {
+		archives = archives[:n]
+	}
+	files := make([]string, len(archives))
+	for i, a := range archives {
+		files[len(files)-1-i] = a.Path
+	}
+	return files, nil
+}
+
+// Read tail of the live log file, and redact.
+func (w *fileLogWriter) bundleLive(opts BundleOptions) ([]byte, error) {
+	max := opts.MaxLiveSize
+	if max <= 0 {
+		max = defaultBundleLiveSize
+	}
+
+	f, err := os.Open(w.CurrentPath())
+	if err != nil {
+		return nil, err
+	}
+	defer safeClose("bundle", f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > max {
+		if _, err = f.Seek(info.Size()-max, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(f, max))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, re := range opts.Redact {
+		content = re.ReplaceAll(content, []byte("[REDACTED]"))
+	}
+	return content, nil
+}
+
+func (w *fileLogWriter) bundleManifest(files []string) ([]byte, error) {
+	var state bytes.Buffer
+	if err := DumpState(&state); err != nil {
+		return nil, err
+	}
+
+	stats, err := w.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(struct {
+		Created time.Time     `json:"created"`
+		Files   []string      `json:"files"`
+		Stats   FileStats     `json:"stats"`
+		Writers []WriterStats `json:"writers"`
+		State   string        `json:"state"`
+	}{hal.Now(), files, stats, AllWriterStats(), state.String()}, "", "  ")
+}
+
+// Add file to tar, returns false if file not exist, such as deleted by
+// retention.
+func addBundleFile(tw *tar.Writer, path string) (bool, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer safeClose("bundle", f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	hdr := &tar.Header{Name: filepath.Base(path), Mode: 0600, Size: info.Size(), ModTime: info.ModTime()}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return false, err
+	}
+	// file may grow, such as audit log, copy exact size in header.
+	_, err = io.CopyN(tw, f, info.Size())
+	return true, err
+}
+
+func addBundleContent(tw *tar.Writer, name string, content []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: hal.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
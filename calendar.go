@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// RotatePeriod is the calendar period of calendar-aligned rotation, see
+// WithCalendarRotation().
+type RotatePeriod int
+
+const (
+	// RotateDaily rotates log file on date change.
+	RotateDaily RotatePeriod = iota + 1
+	// RotateHourly rotates log file on hour change.
+	RotateHourly
+)
+
+type calendarRotation struct {
+	period RotatePeriod
+	loc    *time.Location
+}
+
+// WithCalendarRotation makes file log writer rotate log file when date or
+// hour changes between writes, in addition to size and interval triggers.
+// Date and hour are of loc, nil for local time. Backups are named after the
+// start of their period, such as `app-2024-05-01-000000.log` for daily
+// rotation, so they contain only output of that period.
+//
+// Rotation happens before the first write of a new period, only once even if
+// several periods passed, and skipped if live log file is empty.
+func WithCalendarRotation(period RotatePeriod, loc *time.Location) Option {
+	return func(o *writerOptions) {
+		if loc == nil {
+			loc = time.Local
+		}
+		o.calendar = &calendarRotation{period, loc}
+	}
+}
+
+// Start of the period t belongs to.
+func (c *calendarRotation) start(t time.Time) time.Time {
+	t = t.In(c.loc)
+	switch c.period {
+	case RotateHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, c.loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.loc)
+	}
+}
+
+// Rotate live log file if entered a new calendar period. Must hold w.l.
+func (w *fileLogWriter) rotateCalendarLocked() error {
+	if w.calendar == nil {
+		return nil
+	}
+
+	now := hal.Now()
+	period := w.calendar.start(now)
+	if period.Equal(w.periodStart) {
+		return nil
+	}
+
+	size := atomic.LoadInt64(&w.currentSize)
+	if size == 0 {
+		w.periodStart = period
+		return nil
+	}
+
+	bakFile := w.backupFilenameAt(w.f.Name(), w.periodStart)
+	if debugEnabled() {
+		debugf("rotate: %s period %s ended, backup to %s", w.CurrentPath(), w.periodStart, bakFile)
+	}
+	if err := w.rotateLocked(now, bakFile, size); err != nil {
+		return err
+	}
+	w.periodStart = period
+	return nil
+}
@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Rotated before the first write of a new period, once even if several
+// periods passed, backup named after start of its period.
+func TestCalendarRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   RotatePeriod
+		from, to time.Time
+		archive  string // empty if not rotated
+	}{
+		{"same day", RotateDaily, time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+			time.Date(2024, 5, 1, 23, 59, 59, 0, time.Local), ""},
+		{"midnight", RotateDaily, time.Date(2024, 5, 1, 23, 59, 0, 0, time.Local),
+			time.Date(2024, 5, 2, 0, 0, 1, 0, time.Local), "app-2024-05-01-000000.log.gz"},
+		{"days passed", RotateDaily, time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local),
+			time.Date(2024, 5, 4, 10, 0, 0, 0, time.Local), "app-2024-05-01-000000.log.gz"},
+		{"same hour", RotateHourly, time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local),
+			time.Date(2024, 5, 1, 15, 59, 59, 0, time.Local), ""},
+		{"hour changed", RotateHourly, time.Date(2024, 5, 1, 15, 59, 0, 0, time.Local),
+			time.Date(2024, 5, 1, 16, 0, 0, 0, time.Local), "app-2024-05-01-150000.log.gz"},
+		{"hours passed", RotateHourly, time.Date(2024, 5, 1, 23, 4, 5, 0, time.Local),
+			time.Date(2024, 5, 2, 3, 0, 0, 0, time.Local), "app-2024-05-01-230000.log.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freezeNow(t, tt.from)
+			w := newTestFileWriter(t, 0, 0, WithCalendarRotation(tt.period, nil))
+			if _, err := w.Write([]byte("a\n")); err != nil {
+				t.Fatal(err)
+			}
+			freezeNow(t, tt.to)
+			// rotated by the first write of the new period only
+			for _, s := range []string{"b\n", "c\n"} {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.waitBackground()
+
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantLive := "a\nb\nc\n"
+			if tt.archive == "" {
+				if len(archives) != 0 {
+					t.Errorf("archives %v, want none", archives)
+				}
+			} else {
+				wantLive = "b\nc\n"
+				if len(archives) != 1 {
+					t.Fatalf("archives %v, want %s", archives, tt.archive)
+				}
+				if name := filepath.Base(archives[0].Path); name != tt.archive {
+					t.Errorf("archive %s, want %s", name, tt.archive)
+				}
+				if content := readArchive(t, archives[0].Path); content != "a\n" {
+					t.Errorf("archive content %q", content)
+				}
+			}
+			live, err := ioutil.ReadFile(w.CurrentPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(live) != wantLive {
+				t.Errorf("live log file %q, want %q", live, wantLive)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Compressor compresses backups of file log writer, see WithCompressor().
+type Compressor interface {
+	// Compress compresses file src to dst, dst is a temp file of src
+	// appended with Suffix() and `.tmp`, renamed to the archive after
+	// Compress returns nil. Src deleted by file log writer then, if not
+	// deleted by Compress.
+	Compress(src, dst string) error
+
+	// Suffix is the file name suffix of archives, such as `.gz`, must not be
+	// empty.
+	Suffix() string
+}
+
+var errEmptyCompressorSuffix = errors.New("logging: compressor suffix must not be empty")
+
+// Built-in compression format of archives, implements Compressor, archives
+// of built-in codecs recognized by content.
+type codec struct {
+	suffix      string
+	contentType string
+	magic       []byte
+	newWriter   func(w io.Writer) (io.WriteCloser, error)
+	newReader   func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	gzipCodec = newGzipCodec(gzip.DefaultCompression, 1)
+
+	zstdCodec = &codec{
+		suffix:      `.zst`,
+		contentType: "application/zstd",
+		magic:       []byte{0x28, 0xb5, 0x2f, 0xfd},
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return zstdReader{d}, nil
+		},
+	}
+
+	// all known codecs, archives of all codecs recognized regardless of
+	// codec used by the writer.
+	codecs = []*codec{gzipCodec, zstdCodec}
+)
+
+// GzipCompressor returns the default Compressor, compress backups by gzip to
+// `.gz` archives.
+func GzipCompressor() Compressor {
+	return gzipCodec
+}
+
+// ZstdCompressor returns Compressor compress backups by zstd to `.zst`
+// archives, faster than gzip with better ratio.
+func ZstdCompressor() Compressor {
+	return zstdCodec
+}
+
+func (c *codec) Suffix() string {
+	return c.suffix
+}
+
+func (c *codec) Compress(src, dst string) error {
+	return c.compress(src, dst, 0)
+}
+
+// Compress, reading src limited to rate bytes per second, not limited if rate
+// <= 0.
+func (c *codec) compress(src, dst string, rate int64) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer safeClose("compress", f)
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer safeClose("compress", in)
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := c.newWriter(f)
+	if err != nil {
+		return err
+	}
+	// name and time of the backup in gzip header, such as shown by `gzip -l`
+	switch gw := out.(type) {
+	case *gzip.Writer:
+		gw.Name, gw.ModTime = filepath.Base(src), info.ModTime()
+	case *pgzip.Writer:
+		gw.Name, gw.ModTime = filepath.Base(src), info.ModTime()
+	}
+	var r io.Reader = in
+	if rate > 0 {
+		r = &throttledReader{ctx: context.Background(), r: in, rate: rate, start: time.Now()}
+	}
+	if _, err = io.Copy(out, r); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// block size of parallel gzip, each goroutine compresses a block at a time
+const pgzipBlockSize = 1 << 20
+
+// Returns gzip codec, compress by concurrency goroutines if concurrency > 1,
+// output is standard gzip stream.
+func newGzipCodec(level, concurrency int) *codec {
+	return &codec{
+		suffix:      `.gz`,
+		contentType: "application/gzip",
+		magic:       []byte{0x1f, 0x8b},
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			if concurrency <= 1 {
+				return gzip.NewWriterLevel(w, level)
+			}
+			pw, err := pgzip.NewWriterLevel(w, level)
+			if err != nil {
+				return nil, err
+			}
+			if err = pw.SetConcurrency(pgzipBlockSize, concurrency); err != nil {
+				return nil, err
+			}
+			return pw, nil
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	}
+}
+
+// WithGzipLevel set gzip compression level of backups, such as
+// gzip.BestSpeed, gzip.BestCompression, default to gzip.DefaultCompression.
+// NewFileLogWriter() returns error if level is not accepted by gzip. Ignored
+// if compress backups by other compressor.
+func WithGzipLevel(level int) Option {
+	return func(o *writerOptions) {
+		o.gzipLevel = &level
+	}
+}
+
+// WithGzipConcurrency makes gzip compression of a backup run in n
+// goroutines, each compresses 1MB block at a time, to speed up compression
+// of large backups. Default to 1, compress in single goroutine. Archives are
+// still standard gzip stream, readable by zcat. Ignored if compress backups by
+// other compressor.
+func WithGzipConcurrency(n int) Option {
+	return func(o *writerOptions) {
+		o.gzipConcurrency = n
+	}
+}
+
+// Validate compressor options, and apply gzip options to gzip compressor.
+func resolveCompressor(o *writerOptions) error {
+	if o.compressor != nil && o.compressor.Suffix() == "" {
+		return errEmptyCompressorSuffix
+	}
+	if o.gzipLevel == nil && o.gzipConcurrency <= 1 {
+		return nil
+	}
+	if o.compressor != nil && o.compressor != Compressor(gzipCodec) {
+		return nil
+	}
+
+	level := gzip.DefaultCompression
+	if o.gzipLevel != nil {
+		level = *o.gzipLevel
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return fmt.Errorf("logging: invalid gzip level %d, must between %d and %d", level, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+	o.compressor = newGzipCodec(level, o.gzipConcurrency)
+	return nil
+}
+
+// zstd.Decoder.Close() returns nothing.
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (r zstdReader) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+// WithZstd makes file log writer compress backups by zstd, same as
+// WithCompressor(ZstdCompressor()).
+func WithZstd() Option {
+	return WithCompressor(ZstdCompressor())
+}
+
+// WithCompressor set Compressor of file log writer backups, default to
+// GzipCompressor(). Archives of built-in compressors and c recognized by
+// suffix, and cleaned by retention, so changing compressor on an existing log
+// directory not orphan old archives. Archives of built-in compressors are
+// decompressed by OpenArchive() and verified by VerifyArchives(), archives
+// of other compressors are read as is, and only verified by checksum.
+func WithCompressor(c Compressor) Option {
+	return func(o *writerOptions) {
+		o.compressor = c
+	}
+}
+
+// Returns compressor of backups, default to gzip.
+func (w *fileLogWriter) archiveCompressor() Compressor {
+	if w.compressor == nil {
+		return gzipCodec
+	}
+	return w.compressor
+}
+
+// Returns suffixes of all recognized archives, built-in codecs first.
+func (w *fileLogWriter) archiveSuffixes() []string {
+	r := make([]string, 0, len(codecs)+1)
+	for _, c := range codecs {
+		r = append(r, c.suffix)
+	}
+	if w.compressor != nil && codecBySuffix(w.compressor.Suffix()) == nil {
+		r = append(r, w.compressor.Suffix())
+	}
+	return r
+}
+
+func codecBySuffix(suffix string) *codec {
+	for _, c := range codecs {
+		if c.suffix == suffix {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns compression suffix of archive name, "" if not compressed.
+func (w *fileLogWriter) archiveSuffix(name string) string {
+	name = strings.TrimSuffix(name, encSuffix)
+	for _, suffix := range w.archiveSuffixes() {
+		if strings.HasSuffix(name, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// Trim encryption and compression suffix of archive name.
+func (w *fileLogWriter) trimArchiveSuffix(name string) string {
+	name = strings.TrimSuffix(name, encSuffix)
+	return strings.TrimSuffix(name, w.archiveSuffix(name))
+}
+
+// Detect codec by content, nil if not compressed by a known codec.
+func sniffCodec(br *bufio.Reader) (*codec, error) {
+	for _, c := range codecs {
+		magic, err := br.Peek(len(c.magic))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if bytes.Equal(magic, c.magic) {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,297 @@
+package logging
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns compressible log content of n lines.
+func testLogContent(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "2024-05-01 15:04:05 [INFO] record %d of user %d handled\n", i, i%97)
+	}
+	return b.String()
+}
+
+func TestGzipLevel(t *testing.T) {
+	content := testLogContent(5000)
+	tests := []struct {
+		name    string
+		level   int
+		recover bool // compressed by recovery on start, not rotation
+	}{
+		{"huffman only", gzip.HuffmanOnly, false},
+		{"best speed", gzip.BestSpeed, false},
+		{"best compression", gzip.BestCompression, false},
+		{"huffman only recovered", gzip.HuffmanOnly, true},
+		{"best compression recovered", gzip.BestCompression, true},
+	}
+	sizes := map[string]int64{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			if tt.recover {
+				// backup left uncompressed by last run
+				if err := ioutil.WriteFile(filepath.Join(filepath.Dir(path), "app-2024-05-01-150405.log"), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w := openTestFileWriter(t, path, 0, 0, WithGzipLevel(tt.level))
+			if !tt.recover {
+				if _, err := w.Write([]byte(content)); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.waitBackground()
+
+			archives, err := w.listArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) != 1 || !archives[0].Compressed {
+				t.Fatalf("archives %v, want one compressed", archives)
+			}
+			if got := readArchive(t, archives[0].Path); got != content {
+				t.Errorf("decompressed %d bytes, want %d", len(got), len(content))
+			}
+			sizes[tt.name] = archives[0].Size
+		})
+	}
+
+	// level applied, by both rotation and recovery
+	if !(sizes["huffman only"] > sizes["best speed"] && sizes["best speed"] > sizes["best compression"]) {
+		t.Errorf("archive sizes not ordered by level: %v", sizes)
+	}
+	if sizes["huffman only recovered"] <= sizes["best compression recovered"] {
+		t.Errorf("recovered archive sizes not ordered by level: %v", sizes)
+	}
+}
+
+// Zstd archives verified after compressed, decompressed by OpenArchive(),
+// valid by VerifyArchives(), truncated one corrupt.
+func TestZstd(t *testing.T) {
+	content := testLogContent(1000)
+	w := newTestFileWriter(t, 0, 0, WithZstd(), WithManifest())
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.waitBackground()
+
+	archives, err := w.ListArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || !archives[0].Compressed || filepath.Ext(archives[0].Path) != ".zst" {
+		t.Fatalf("archives %v, want one zstd archive", archives)
+	}
+	if got := readArchiveOf(t, archives[0].Path); got != content {
+		t.Errorf("decompressed %d bytes, want %d", len(got), len(content))
+	}
+	results, err := w.VerifyArchives(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != VerifyOK {
+		t.Errorf("verify results %+v", results)
+	}
+
+	if err := os.Truncate(archives[0].Path, archives[0].Size/2); err != nil {
+		t.Fatal(err)
+	}
+	if results, err = w.VerifyArchives(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Status != VerifyCorrupt {
+		t.Errorf("verify results of truncated %+v", results)
+	}
+}
+
+// Retention counts gzip archives of earlier runs and zstd archives together,
+// oldest deleted first.
+func TestRetentionMixedCodecs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	base := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	rotate := func(w *fileLogWriter, i int) {
+		freezeNow(t, base.Add(time.Duration(i)*time.Second))
+		if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		w.waitBackground()
+	}
+
+	w := openTestFileWriter(t, path, 0, 3)
+	rotate(w, 0)
+	rotate(w, 1)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	w = openTestFileWriter(t, path, 0, 3, WithZstd())
+	rotate(w, 2)
+	rotate(w, 3)
+
+	archives, err := w.ListArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"app-2024-05-01-150408.log.zst",
+		"app-2024-05-01-150407.log.zst",
+		"app-2024-05-01-150406.log.gz",
+	}
+	if len(archives) != len(want) {
+		t.Fatalf("archives %v, want %v", archives, want)
+	}
+	for i, a := range archives {
+		if name := filepath.Base(a.Path); name != want[i] {
+			t.Errorf("archive %d %s, want %s", i, name, want[i])
+		}
+		if got, wantContent := readArchiveOf(t, a.Path), fmt.Sprintf("line %d\n", 3-i); got != wantContent {
+			t.Errorf("%s content %q, want %q", a.Path, got, wantContent)
+		}
+	}
+}
+
+// Returns decompressed content of archive of any built-in codec.
+func readArchiveOf(t testing.TB, path string) string {
+	t.Helper()
+	r, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestGzipLevelInvalid(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly - 1, gzip.BestCompression + 1} {
+		path := filepath.Join(t.TempDir(), "app.log")
+		if w, err := NewFileLogWriter(path, 0, 0, WithoutRegistry(), WithGzipLevel(level)); err == nil {
+			_ = w.Close()
+			t.Errorf("level %d accepted", level)
+		}
+	}
+}
+
+// Gzip header has name and modification time of the backup, archive has
+// modification time of the backup.
+func TestGzipHeader(t *testing.T) {
+	mtime := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"gzip", nil},
+		{"parallel gzip", []Option{WithGzipConcurrency(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestFileWriter(t, 0, 0, tt.opts...)
+			if _, err := w.Write([]byte(testLogContent(100))); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(w.CurrentPath(), mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+			w.waitBackground()
+
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) != 1 || !archives[0].Compressed {
+				t.Fatalf("archives %v, want one compressed", archives)
+			}
+			path := archives[0].Path
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("archive modification time %s, want %s", info.ModTime(), mtime)
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			r, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Base(w.trimArchiveSuffix(path)); r.Name != want {
+				t.Errorf("gzip header name %q, want %q", r.Name, want)
+			}
+			if !r.ModTime.Equal(mtime) {
+				t.Errorf("gzip header modification time %s, want %s", r.ModTime, mtime)
+			}
+			content, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != testLogContent(100) {
+				t.Error("content changed")
+			}
+		})
+	}
+}
+
+// Compress a 256MB backup by 1 and more goroutines, run with -bench and
+// -benchtime=1x for large file.
+func BenchmarkGzipConcurrency(b *testing.B) {
+	const size = 256 << 20
+	src := filepath.Join(b.TempDir(), "app-2024-05-01-150405.log")
+	f, err := os.Create(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	chunk := []byte(testLogContent(10000))
+	var written int64
+	for written < size {
+		if _, err = f.Write(chunk); err != nil {
+			b.Fatal(err)
+		}
+		written += int64(len(chunk))
+	}
+	if err = f.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			c := newGzipCodec(gzip.DefaultCompression, n)
+			dst := src + c.Suffix()
+			b.SetBytes(written)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Compress(src, dst); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
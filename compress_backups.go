@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"os"
+)
+
+// CompressBackups compresses all uncompressed backups now, such as left by
+// crash, before snapshot of the volume, with the configured compressor, backups
+// kept uncompressed by WithKeepUncompressed() included. Run by the background
+// worker after queued jobs, a backup never compressed twice concurrently.
+// Returns the first error, the rest backups still compressed.
+func (w *fileLogWriter) CompressBackups() (err error) {
+	w.runByWorker(func() {
+		err = w.compressBackups()
+	})
+	return
+}
+
+// CompressBackups compresses all uncompressed backups of the alive file log
+// writer of path, see CompressBackups method of file log writer. Returns
+// ErrNoFileLogWriter if no such writer, backups compressed by its options.
+func CompressBackups(path string) error {
+	w, err := registeredFileWriter(path)
+	if err != nil {
+		return err
+	}
+	return w.CompressBackups()
+}
+
+func (w *fileLogWriter) compressBackups() (first error) {
+	for _, path := range w.datedPaths() {
+		var files []string
+		var err error
+		if w.numbered {
+			// backups not renumbered yet compressed by their own job,
+			// renumberL not locked, renumbering runs by the worker too,
+			// held by it if called from OnRotate callback
+			files, err = w.getNumberedFiles(path, ``)
+		} else {
+			files, err = w.getUncompressedFiles(path)
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+		for _, f := range files {
+			info, err := os.Stat(f)
+			if os.IsNotExist(err) {
+				// deleted by retention
+				continue
+			}
+			if err == nil {
+				err = w.archive(f, w.backupTime(path, f), info.Size())
+			}
+			if err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
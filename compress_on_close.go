@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"os"
+	"sync/atomic"
+
+	"github.com/redforks/hal"
+)
+
+// WithCompressOnClose makes Close of file log writer rotate the live log file
+// as the final rotation, the backup compressed and old backups cleaned before
+// Close returns, at most WithCloseTimeout(), no plain log file left behind,
+// such as for short-lived batch jobs. Skipped if the log file empty, or
+// closed by abort hook of life package, logs of a crashed process left as is,
+// rotated on next start as usual.
+func WithCompressOnClose() Option {
+	return func(o *writerOptions) {
+		o.compressOnClose = true
+	}
+}
+
+// Mark the writer closed by abort, no final rotation on Close, see
+// WithCompressOnClose().
+func (w *fileLogWriter) markAborting() {
+	atomic.StoreInt32(&w.aborting, 1)
+}
+
+// Rotate the live log file on Close, then remove the new empty log file, see
+// WithCompressOnClose(). Must hold w.l.
+func (w *fileLogWriter) finalRotateLocked() error {
+	if w.frozen != nil {
+		// records written while frozen belong to the backup
+		w.thawLocked(w.frozen)
+	}
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	size := atomic.LoadInt64(&w.currentSize)
+	if size <= w.headerLen {
+		return nil
+	}
+
+	bakFile := w.newBackupFilename(w.f.Name())
+	if debugEnabled() {
+		debugf("rotate: %s on close, size %d, backup to %s", w.CurrentPath(), size, bakFile)
+	}
+	if err := w.rotateLocked(hal.Now(), bakFile, size); err != nil {
+		return err
+	}
+	if w.copyTruncate {
+		// the log file can not be removed, see WithCopyTruncate()
+		return nil
+	}
+
+	name := w.f.Name()
+	err := w.f.Close()
+	w.f = nil
+	if e := os.Remove(name); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"sync"
+)
+
+const defaultMaxCompressions = 2
+
+// Running compressions of all file log writers, rotation and startup
+// recovery share the limit.
+var compressPool = struct {
+	l       sync.Mutex
+	cond    *sync.Cond
+	max     int
+	running int
+}{
+	max: defaultMaxCompressions,
+}
+
+func init() {
+	compressPool.cond = sync.NewCond(&compressPool.l)
+}
+
+// SetMaxCompressions set max concurrent compressions of backups, of all
+// file log writers, default to 2. Compressions exceed the limit wait in
+// background, such as many backups left uncompressed on start, never block
+// Write.
+func SetMaxCompressions(n int) {
+	if n < 1 {
+		n = 1
+	}
+	compressPool.l.Lock()
+	compressPool.max = n
+	compressPool.l.Unlock()
+	compressPool.cond.Broadcast()
+}
+
+// WithCompressRate limits reading of backups by built-in compressors to
+// bytesPerSecond, so compression not saturates the disk, applies to
+// compressions after rotation and on startup recovery, and verification of
+// archives. Compressions of the writer run one at a time, share the limit.
+// Not limited by default, or if bytesPerSecond <= 0.
+func WithCompressRate(bytesPerSecond int64) Option {
+	return func(o *writerOptions) {
+		o.compressRate = bytesPerSecond
+	}
+}
+
+// Wait for a compression slot, call releaseCompressSlot() after done.
+func acquireCompressSlot() {
+	compressPool.l.Lock()
+	defer compressPool.l.Unlock()
+
+	for compressPool.running >= compressPool.max {
+		compressPool.cond.Wait()
+	}
+	compressPool.running++
+}
+
+func releaseCompressSlot() {
+	compressPool.l.Lock()
+	compressPool.running--
+	compressPool.l.Unlock()
+	compressPool.cond.Signal()
+}
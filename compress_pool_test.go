@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Gzip compressor records max concurrent compressions.
+type countingCompressor struct {
+	l       sync.Mutex
+	running int
+	max     int
+	total   int
+}
+
+func (c *countingCompressor) Compress(src, dst string) error {
+	c.l.Lock()
+	c.running++
+	c.total++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	c.l.Unlock()
+	defer func() {
+		c.l.Lock()
+		c.running--
+		c.l.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	return codecBySuffix(c.Suffix()).Compress(src, dst)
+}
+
+func (c *countingCompressor) Suffix() string { return `.gz` }
+
+// Many backups left by last run of several writers, recovery and rotation
+// compressions bounded by SetMaxCompressions().
+func TestMaxCompressions(t *testing.T) {
+	const writers, pending = 4, 5
+	for _, n := range []int{1, 2, 3} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			SetMaxCompressions(n)
+			defer SetMaxCompressions(defaultMaxCompressions)
+
+			c := &countingCompressor{}
+			var ws []*fileLogWriter
+			for i := 0; i < writers; i++ {
+				dir := t.TempDir()
+				for j := 0; j < pending; j++ {
+					name := filepath.Join(dir, fmt.Sprintf("app-2024-05-01-1504%02d.log", j))
+					if err := ioutil.WriteFile(name, []byte("left by last run\n"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				w := openTestFileWriter(t, filepath.Join(dir, "app.log"), 0, 0, WithCompressor(c))
+				// rotation during recovery
+				if _, err := w.Write([]byte("line\n")); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+				ws = append(ws, w)
+			}
+			for _, w := range ws {
+				w.waitBackground()
+			}
+
+			c.l.Lock()
+			defer c.l.Unlock()
+			if c.total != writers*(pending+1) {
+				t.Errorf("compressed %d backups, want %d", c.total, writers*(pending+1))
+			}
+			if c.max > n {
+				t.Errorf("max concurrent compressions %d, limit %d", c.max, n)
+			}
+		})
+	}
+}
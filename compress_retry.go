@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Backoff of retrying failed compressions, doubled on each retry until all
+// succeeded, replaced to simulate.
+var (
+	minCompressRetry = time.Minute
+	maxCompressRetry = 30 * time.Minute
+)
+
+// Failed attempts of a backup before give up, the backup left uncompressed,
+// counted by retention, compressed by recovery on next start.
+var maxCompressAttempts = 10
+
+// Backups failed to compress, such as disk full, retried by the background
+// worker with backoff, until compressed, deleted or give up.
+type compressRetries struct {
+	l        sync.Mutex
+	failed   map[string]failedCompression
+	attempts int         // retries since all succeeded
+	timer    *time.Timer // pending retry, nil if not scheduled
+}
+
+type failedCompression struct {
+	rotatedAt time.Time
+	attempts  int // failed attempts
+}
+
+// Record failed compression of bakFile, schedule a retry if not scheduled,
+// give up if failed maxCompressAttempts times.
+func (w *fileLogWriter) compressFailed(bakFile string, rotatedAt time.Time) {
+	if attempts, giveUp := w.scheduleCompressRetry(bakFile, rotatedAt); giveUp {
+		w.reportError("compress", fmt.Errorf("logging: give up compressing %s after %d attempts", bakFile, attempts))
+	}
+}
+
+// Returns failed attempts of bakFile, true if given up.
+func (w *fileLogWriter) scheduleCompressRetry(bakFile string, rotatedAt time.Time) (int, bool) {
+	r := &w.compressRetry
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.failed == nil {
+		r.failed = map[string]failedCompression{}
+	}
+	f := r.failed[bakFile]
+	f.rotatedAt = rotatedAt
+	if f.attempts++; f.attempts >= maxCompressAttempts {
+		delete(r.failed, bakFile)
+		if len(r.failed) == 0 {
+			r.attempts = 0
+		}
+		return f.attempts, true
+	}
+	r.failed[bakFile] = f
+	if r.timer != nil {
+		return f.attempts, false
+	}
+
+	d := maxCompressRetry
+	if r.attempts < 16 && minCompressRetry<<r.attempts < maxCompressRetry {
+		d = minCompressRetry << r.attempts
+	}
+	r.attempts++
+	if debugEnabled() {
+		debugf("compress: %s failed, retry in %s", bakFile, d)
+	}
+	r.timer = time.AfterFunc(d, func() {
+		r.l.Lock()
+		r.timer = nil
+		r.l.Unlock()
+		w.enqueueJob("compress-retry", w.retryCompressions)
+	})
+	return f.attempts, false
+}
+
+// Forget bakFile compressed, or deleted.
+func (w *fileLogWriter) compressSucceeded(bakFile string) {
+	r := &w.compressRetry
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	delete(r.failed, bakFile)
+	if len(r.failed) == 0 {
+		r.attempts = 0
+	}
+}
+
+// Track failed backup renamed, such as renumbered.
+func (w *fileLogWriter) compressRenamed(from, to string) {
+	r := &w.compressRetry
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if f, ok := r.failed[from]; ok {
+		delete(r.failed, from)
+		r.failed[to] = f
+	}
+}
+
+// Returns number of backups failed to compress, waiting for retry.
+func (w *fileLogWriter) failedCompressions() int {
+	r := &w.compressRetry
+	r.l.Lock()
+	defer r.l.Unlock()
+	return len(r.failed)
+}
+
+// Stop pending retry, retried on next start by recovery.
+func (r *compressRetries) stop() {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+// Compress failed backups again. Called in background worker.
+func (w *fileLogWriter) retryCompressions() {
+	w.l.Lock()
+	closed := w.closed
+	w.l.Unlock()
+	if closed {
+		return
+	}
+
+	w.compressRetry.l.Lock()
+	failed := make(map[string]time.Time, len(w.compressRetry.failed))
+	for f, c := range w.compressRetry.failed {
+		failed[f] = c.rotatedAt
+	}
+	w.compressRetry.l.Unlock()
+
+	for f, t := range failed {
+		if w.numbered {
+			// not renumbered while compressing
+			w.renumberL.Lock()
+		}
+		info, err := os.Stat(f)
+		if err != nil {
+			// deleted by retention
+			w.compressSucceeded(f)
+		} else if err = w.archive(f, t, info.Size()); err != nil {
+			w.reportError("compress", err)
+		}
+		if w.numbered {
+			w.renumberL.Unlock()
+		}
+	}
+}
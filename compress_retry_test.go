@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// Replace retry backoff and attempts limit, restored on cleanup.
+func setCompressRetry(t *testing.T, min time.Duration, attempts int) {
+	t.Helper()
+	oldMin, oldMax, oldAttempts := minCompressRetry, maxCompressRetry, maxCompressAttempts
+	minCompressRetry, maxCompressRetry, maxCompressAttempts = min, 100*min, attempts
+	t.Cleanup(func() {
+		minCompressRetry, maxCompressRetry, maxCompressAttempts = oldMin, oldMax, oldAttempts
+	})
+}
+
+func TestCompressRetry(t *testing.T) {
+	const min = 20 * time.Millisecond
+	setCompressRetry(t, min, 10)
+	c := &flakyCompressor{fails: 2}
+	w := newTestFileWriter(t, 0, 0, WithCompressor(c))
+	rotateTimes(t, w, 1)
+	if n := w.failedCompressions(); n != 1 {
+		t.Errorf("failed compressions %d, want 1", n)
+	}
+
+	waitFor(t, "compressed", func() bool {
+		archives, err := w.getCompressedFiles(w.CurrentPath())
+		return err == nil && len(archives) == 1
+	})
+	w.waitBackground()
+	calls := c.attempts()
+	if len(calls) != 3 {
+		t.Fatalf("compress attempts %d, want 3", len(calls))
+	}
+	// backoff doubled on each retry
+	for i, want := range []time.Duration{min, 2 * min} {
+		if d := calls[i+1].Sub(calls[i]); d < want {
+			t.Errorf("retry %d after %s, want >= %s", i+1, d, want)
+		}
+	}
+	if n := w.failedCompressions(); n != 0 {
+		t.Errorf("failed compressions %d, want 0", n)
+	}
+
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || !archives[0].Compressed {
+		t.Fatalf("archives %v, want one compressed, no plain backup left", archives)
+	}
+	if content := readArchive(t, archives[0].Path); content != "line 0\n" {
+		t.Errorf("archive content %q", content)
+	}
+}
+
+func TestCompressRetryGiveUp(t *testing.T) {
+	setCompressRetry(t, time.Millisecond, 3)
+	c := &flakyCompressor{fails: 100}
+	w := newTestFileWriter(t, 0, 0, WithCompressor(c))
+	rotateTimes(t, w, 1)
+
+	waitFor(t, "give up", func() bool { return w.failedCompressions() == 0 })
+	time.Sleep(50 * time.Millisecond)
+	w.waitBackground()
+	if n := len(c.attempts()); n != 3 {
+		t.Errorf("compress attempts %d, want 3", n)
+	}
+
+	// left uncompressed, still managed by retention
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || archives[0].Compressed {
+		t.Fatalf("archives %v, want one uncompressed", archives)
+	}
+	if _, err := os.Stat(archives[0].Path); err != nil {
+		t.Error(err)
+	}
+}
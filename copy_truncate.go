@@ -0,0 +1,30 @@
+package logging
+
+import (
+	"os"
+)
+
+// WithCopyTruncate makes file log writer rotate by copying the live log file
+// to backup then truncating it in place, instead of rename and reopen, like
+// copytruncate of logrotate, for log file can not be renamed, such as bind
+// mounted into another container. The writer keeps the same file handle, the
+// backup compressed and cleaned as usual.
+//
+// Records written by the writer never lost, writes blocked during rotation,
+// but records appended by other processes between copy and truncate are
+// lost. Rotation copies the whole log file, takes longer than rename.
+func WithCopyTruncate() Option {
+	return func(o *writerOptions) {
+		o.copyTruncate = true
+	}
+}
+
+// Copy the live log file to bakFile then truncate it. Must hold w.l.
+func (w *fileLogWriter) copyTruncateLocked(bakFile string) error {
+	if err := copyFile(w.f.Name(), bakFile); err != nil {
+		_ = os.Remove(bakFile)
+		return err
+	}
+	// data appended by others since copy lost, see WithCopyTruncate()
+	return w.f.Truncate(0)
+}
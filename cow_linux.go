@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"syscall"
+)
+
+// statfs magic numbers of copy-on-write filesystems
+const (
+	btrfsMagic    = 0x9123683e
+	zfsMagic      = 0x2fc12fc1
+	bcachefsMagic = 0xca451a4e
+)
+
+// Returns true if path on a copy-on-write filesystem.
+func isCopyOnWrite(path string) (bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false, err
+	}
+	switch uint32(st.Type) {
+	case btrfsMagic, zfsMagic, bcachefsMagic:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package logging
+
+// Copy-on-write filesystem detection only supported on linux.
+func isCopyOnWrite(path string) (bool, error) {
+	return false, nil
+}
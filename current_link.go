@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// default suffix of current symlink, see WithCurrentLink()
+const currentLinkSuffix = `.current`
+
+// WithCurrentLink maintains symlink link points to the live log file, a
+// stable path for tools such as `tail -F`, "" for `<logfile>.current`. The
+// link updated atomically, by creating a temp symlink then rename, after the
+// live log file opened and rotated. If symlink not supported, reported as
+// internal error once, writes not affected.
+func WithCurrentLink(link string) Option {
+	return func(o *writerOptions) {
+		o.currentLink, o.currentLinkOn = link, true
+	}
+}
+
+// Update current link points to target.
+func (w *fileLogWriter) updateCurrentLink(target string) {
+	if w.currentLink == "" {
+		return
+	}
+
+	// relative link if in the same directory, keeps working if the
+	// directory moved or mounted elsewhere
+	if filepath.Dir(target) == filepath.Dir(w.currentLink) {
+		target = filepath.Base(target)
+	}
+	if dest, err := os.Readlink(w.currentLink); err == nil && dest == target {
+		return
+	}
+
+	tmp := w.currentLink + `.tmp`
+	_ = os.Remove(tmp)
+	err := os.Symlink(target, tmp)
+	if err == nil {
+		if err = os.Rename(tmp, w.currentLink); err != nil {
+			_ = os.Remove(tmp)
+		}
+	}
+	if err != nil {
+		if atomic.CompareAndSwapInt32(&w.currentLinkFailed, 0, 1) {
+			w.reportError("file", fmt.Errorf("update current link %s: %w", w.currentLink, err))
+		}
+		return
+	}
+	atomic.StoreInt32(&w.currentLinkFailed, 0)
+}
@@ -0,0 +1,266 @@
+package logging
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+const (
+	bundleSuffix = `.tar.gz`
+	bundleLayout = `2006-01-02`
+)
+
+// WithDailyBundles packs archives rotated before olderThan ago into one
+// tar.gz per day of rotation time, such as `app-2024-05-01.tar.gz`, in the
+// background worker after compression, archives added to the existing bundle
+// of the day. Reduces small files if rotated often, such as hourly.
+//
+// A bundle counted as one archive by retention, rotated at the end of its day.
+// OpenArchive() reads a bundle as concatenation of its archives. Adopted
+// backups not bundled, and signatures of bundled archives removed.
+func WithDailyBundles(olderThan time.Duration) Option {
+	return func(o *writerOptions) {
+		o.bundleAge = olderThan
+	}
+}
+
+// Returns bundle path of the log file for day.
+func (w *fileLogWriter) bundlePath(logfilename string, day time.Time) string {
+	prefix, _ := w.backupNameParts(logfilename)
+	return prefix + day.Format(bundleLayout) + bundleSuffix
+}
+
+// Parse day of bundle, false if not a bundle of the log file.
+func (w *fileLogWriter) bundleDay(logfilename, name string) (time.Time, bool) {
+	prefix, _ := w.backupNameParts(logfilename)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, bundleSuffix) || len(name) < len(prefix)+len(bundleSuffix) {
+		return time.Time{}, false
+	}
+	day, err := time.ParseInLocation(bundleLayout, name[len(prefix):len(name)-len(bundleSuffix)], w.backupLocation())
+	return day, err == nil
+}
+
+// Returns bundles of the log file.
+func (w *fileLogWriter) getBundles(logfilename string) ([]string, error) {
+	prefix, _ := w.backupNameParts(logfilename)
+	matches, err := filepath.Glob(globEscape(prefix) + `*` + bundleSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	files := matches[:0]
+	for _, f := range matches {
+		if _, ok := w.bundleDay(logfilename, f); ok {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Pack archives older than bundleAge to bundles of their days. Called in
+// background worker.
+func (w *fileLogWriter) bundleOldArchives() error {
+	for _, path := range w.datedPaths() {
+		if err := w.bundleOldArchivesOf(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *fileLogWriter) bundleOldArchivesOf(path string) error {
+	archives, err := w.getCompressedFiles(path)
+	if err != nil {
+		return err
+	}
+
+	deadline := hal.Now().Add(-w.bundleAge)
+	days := map[string][]string{}
+	for _, f := range archives {
+		if _, ok := w.adoptedTime(f); ok {
+			continue
+		}
+		t := w.backupTime(path, f)
+		if !t.Before(deadline) {
+			continue
+		}
+		bundle := w.bundlePath(path, t.In(w.backupLocation()))
+		days[bundle] = append(days[bundle], f)
+	}
+
+	for bundle, files := range days {
+		if err := w.appendBundle(bundle, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add files to bundle, created if not exist, then delete files. Rewrite bundle
+// to a temp file then rename, the bundle always complete, files added again
+// if crashed before deleted.
+func (w *fileLogWriter) appendBundle(bundle string, files []string) error {
+	if debugEnabled() {
+		debugf("bundle: add %v to %s", files, bundle)
+	}
+	tmp := bundle + `.tmp`
+	if err := writeBundle(tmp, bundle, files); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, w.createMode()); err != nil {
+		w.reportError("bundle", err)
+	}
+	if err := os.Rename(tmp, bundle); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(bundle))
+
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if err := w.removeArchive(f); err != nil {
+			return err
+		}
+		removeSidecars(f)
+		w.audit.record(auditEntry{Action: auditBundle, File: f, Target: bundle, SizeBefore: info.Size()})
+	}
+	return nil
+}
+
+// Write tar.gz of entries of existing bundle, if exists, and files to dst.
+// Entries replaced by files of the same name.
+func writeBundle(dst, bundle string, files []string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer safeClose("bundle", f)
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	added := make(map[string]bool, len(files))
+	for _, name := range files {
+		added[filepath.Base(name)] = true
+	}
+	if err = copyBundleEntries(tw, bundle, added); err != nil {
+		return err
+	}
+	for _, name := range files {
+		if _, err = addBundleFile(tw, name); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Copy entries of bundle to tw, except names in skip, no-op if bundle not
+// exist.
+func copyBundleEntries(tw *tar.Writer, bundle string, skip map[string]bool) error {
+	f, err := os.Open(bundle)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer safeClose("bundle", f)
+
+	gz, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if skip[hdr.Name] {
+			continue
+		}
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// tar magic at offset 257 of a tar stream, "ustar\x00" or "ustar " of GNU
+// format.
+const tarMagicOffset = 257
+
+// Returns true if br is a tar stream.
+func isTar(br *bufio.Reader) bool {
+	header, err := br.Peek(tarMagicOffset + 5)
+	return err == nil && string(header[tarMagicOffset:]) == "ustar"
+}
+
+// Reads a bundle as concatenation of its archives, each decrypted and
+// decompressed.
+type bundleReader struct {
+	tr  *tar.Reader
+	key KeyFunc
+	cur io.ReadCloser // current entry, nil if not opened
+	dec io.ReadCloser // decompressor of the bundle
+}
+
+func (r *bundleReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			hdr, err := r.tr.Next()
+			if err != nil {
+				return 0, err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if r.cur, err = openArchiveReader(bufio.NewReader(r.tr), r.key); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := r.cur.Read(p)
+		if err != io.EOF {
+			return n, err
+		}
+		err = r.cur.Close()
+		r.cur = nil
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (r *bundleReader) Close() error {
+	if r.cur != nil {
+		if err := r.cur.Close(); err != nil {
+			safeClose("archive", r.dec)
+			return err
+		}
+	}
+	return r.dec.Close()
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redforks/hal"
+)
+
+var (
+	debugOn     int32 // 1 if debug mode enabled, access by atomic
+	debugLock   sync.Mutex
+	debugWriter io.Writer
+)
+
+// SetDebug enables debug mode, trace rotation, compression and retention
+// decisions to w, nil to disable. w should not be a writer of the logging
+// pipeline being debugged.
+//
+// Debug mode also enabled to stderr if environment variable LOGGING_DEBUG=1.
+func SetDebug(w io.Writer) {
+	debugLock.Lock()
+	defer debugLock.Unlock()
+
+	debugWriter = w
+	if w == nil {
+		atomic.StoreInt32(&debugOn, 0)
+	} else {
+		atomic.StoreInt32(&debugOn, 1)
+	}
+}
+
+// Returns true if debug mode enabled, check it before calling debugf to
+// avoid formatting arguments.
+func debugEnabled() bool {
+	return atomic.LoadInt32(&debugOn) == 1
+}
+
+func debugf(format string, args ...interface{}) {
+	debugLock.Lock()
+	defer debugLock.Unlock()
+
+	if debugWriter == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(debugWriter, "%s [%s] debug: %s\n", hal.Now().Format(`2006/01/02 15:04:05.000000`), tag, fmt.Sprintf(format, args...))
+}
+
+func init() {
+	if os.Getenv("LOGGING_DEBUG") == "1" {
+		SetDebug(os.Stderr)
+	}
+}
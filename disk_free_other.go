@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package logging
+
+// Free space check not supported, ok is false.
+func diskFree(path string) (free int64, ok bool, err error) {
+	return 0, false, nil
+}
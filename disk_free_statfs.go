@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package logging
+
+import (
+	"syscall"
+)
+
+// Returns free bytes of the file system of path available to unprivileged
+// users.
+func diskFree(path string) (free int64, ok bool, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false, err
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), true, nil
+}
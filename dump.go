@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// Writers implement workerStatus, describing their internal goroutines and
+// flags.
+type workerStatuser interface {
+	workerStatus() string
+}
+
+// DumpState writes internal state of logging to w: options applied by config,
+// resolved paths, statistics and worker status of each writer, and recent
+// internal errors. It does not use logging pipeline, w should not be a
+// writer created by this package.
+func DumpState(w io.Writer) error {
+	registryLock.Lock()
+	o, logFile := currentOption, currentLogFile
+	registryLock.Unlock()
+
+	p := &dumpPrinter{w: w}
+	p.printf("logging state at %s\n", hal.Now().Format(time.RFC3339))
+	if o != nil {
+		p.printf("options: %+v\n", *o)
+		p.printf("log file: %s\n", logFile)
+	} else {
+		p.printf("options: not initialized by config\n")
+	}
+	p.printf("log dir: %s\n", safeLogDir())
+
+	status, problems := Health()
+	p.printf("health: %s\n", status)
+	for _, item := range problems {
+		p.printf("  problem: %s %s since %s: %v\n", item.Writer, item.Status, item.Since.Format(time.RFC3339), item.Err)
+	}
+
+	for _, rw := range registeredWriters() {
+		s := rw.stats()
+		p.printf("writer %s:\n", s.ID)
+		p.printf("  kind: %s, path: %s\n", s.Kind, s.Path)
+		p.printf("  written: %d, dropped: %d, rotations: %d, compress failures: %d, inner errors: %d\n",
+			s.Written, s.Dropped, s.Rotations, s.CompressFailures, s.InnerErrors)
+		p.printf("  file size: %d, queue: %d/%d, queue max: %d\n", s.FileSize, s.QueueDepth, s.QueueCap, s.QueueMax)
+		if !s.LastRotation.IsZero() {
+			p.printf("  last rotation: %s\n", s.LastRotation.Format(time.RFC3339))
+		}
+		if ws, ok := rw.(workerStatuser); ok {
+			p.printf("  %s\n", ws.workerStatus())
+		}
+	}
+
+	p.printf("recent internal errors:\n")
+	for _, e := range getRecentErrors() {
+		p.printf("  %s %s: %s\n", e.Time.Format(time.RFC3339), e.Component, e.Err)
+	}
+	return p.err
+}
+
+// GetLogDir panics on unsupported OS.
+func safeLogDir() (dir string) {
+	defer func() {
+		if r := recover(); r != nil {
+			dir = fmt.Sprint(r)
+		}
+	}()
+	return GetLogDir()
+}
+
+// Printer remembers first error.
+type dumpPrinter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *dumpPrinter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+// HandleDumpSignal dumps internal state to stderr by DumpState() when
+// receive one of the signals, SIGQUIT if no signal specified. Call the
+// returned function to stop handling.
+func HandleDumpSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGQUIT}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				_ = DumpState(os.Stderr)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
@@ -0,0 +1,311 @@
+package logging
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeyFunc returns a 32 bytes AES-256 key, such as loaded from a file or KMS.
+type KeyFunc func() ([]byte, error)
+
+// KeyFile returns KeyFunc reads key from file, the file contains 32 raw bytes,
+// or 64 hex characters.
+func KeyFile(path string) KeyFunc {
+	return func() ([]byte, error) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if s := strings.TrimSpace(string(content)); len(s) == 2*encKeySize {
+			if key, err := hex.DecodeString(s); err == nil {
+				return key, nil
+			}
+		}
+		return content, nil
+	}
+}
+
+// WithEncryption encrypts compressed archives by AES-256-GCM, each archive
+// has its own data key, wrapped by the key returned by key. Encrypted
+// archive named "<backup>.gz.enc", plain text archive removed after
+// encrypted file synced to disk.
+//
+// NewFileLogWriter fails if key returns error or invalid key, key error on
+// rotation reported as internal error, and the plain text archive kept.
+func WithEncryption(key KeyFunc) Option {
+	return func(o *writerOptions) {
+		o.encryptKey = key
+	}
+}
+
+const (
+	encSuffix = `.enc`
+
+	encKeySize   = 32
+	encChunkSize = 64 * 1024
+	encMagic     = "RFLE"
+	encVersion   = 1
+
+	// nonce prefix of data chunks, followed by 4 bytes chunk counter and 1
+	// byte last chunk flag
+	encNoncePrefix = 7
+)
+
+var (
+	// ErrArchiveEncrypted returned if open encrypted archive without key.
+	ErrArchiveEncrypted = errors.New("logging: archive encrypted, key required")
+
+	errEncTruncated = errors.New("logging: encrypted archive truncated")
+)
+
+func loadKey(key KeyFunc) (cipher.AEAD, error) {
+	k, err := key()
+	if err != nil {
+		return nil, err
+	}
+	if len(k) != encKeySize {
+		return nil, fmt.Errorf("logging: encryption key must be %d bytes, got %d", encKeySize, len(k))
+	}
+	return newGCM(k)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt archive to archive.enc, remove archive after encrypted file synced.
+func (w *fileLogWriter) encryptArchive(archive string) (err error) {
+	kek, err := loadKey(w.key)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer safeClose("encrypt", src)
+
+	tmp := archive + encSuffix + `.tmp`
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			safeClose("encrypt", f)
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if err = encryptStream(f, src, kek); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if info, err := src.Stat(); err == nil {
+		// keep modification time of the archive, see compress()
+		if err = os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+			w.reportError("encrypt", err)
+		}
+	}
+	if err = os.Rename(tmp, archive+encSuffix); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(archive))
+	return os.Remove(archive)
+}
+
+// Fsync file of path.
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Sync directory entries to disk, errors ignored, not supported on some
+// platforms.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		safeClose("encrypt", d)
+	}
+}
+
+// Format: magic, version, wrap nonce, wrapped data key, data nonce prefix,
+// then chunks of: last flag byte, 4 bytes ciphertext length, ciphertext.
+func encryptStream(dst io.Writer, src io.Reader, kek cipher.AEAD) error {
+	dataKey := make([]byte, encKeySize)
+	wrapNonce := make([]byte, kek.NonceSize())
+	prefix := make([]byte, encNoncePrefix)
+	for _, b := range [][]byte{dataKey, wrapNonce, prefix} {
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return err
+		}
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+
+	header := append([]byte(encMagic), encVersion)
+	header = append(header, wrapNonce...)
+	header = kek.Seal(header, wrapNonce, dataKey, []byte(encMagic))
+	header = append(header, prefix...)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(src, encChunkSize)
+	buf := make([]byte, encChunkSize)
+	var out []byte
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			// exactly chunk size, last if nothing follows
+			if _, e := br.Peek(1); e == io.EOF {
+				last = true
+			}
+		}
+
+		out = aead.Seal(out[:0], chunkNonce(prefix, counter, last), buf[:n], nil)
+		var hdr [5]byte
+		if last {
+			hdr[0] = 1
+		}
+		binary.BigEndian.PutUint32(hdr[1:], uint32(len(out)))
+		if _, err := dst.Write(hdr[:]); err != nil {
+			return err
+		}
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, encNoncePrefix+5)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encNoncePrefix:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// Returns true if r starts with encryption magic.
+func isEncrypted(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(encMagic))
+	return string(magic) == encMagic
+}
+
+// Decrypts stream written by encryptStream.
+type decryptReader struct {
+	src    io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+
+	counter uint32
+	buf     []byte // decrypted pending of current chunk
+	done    bool   // last chunk read
+	ct      []byte
+}
+
+func newDecryptReader(src io.Reader, key KeyFunc) (*decryptReader, error) {
+	if key == nil {
+		return nil, ErrArchiveEncrypted
+	}
+	kek, err := loadKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(encMagic)+1+kek.NonceSize()+encKeySize+kek.Overhead()+encNoncePrefix)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, errEncTruncated
+	}
+	if string(header[:len(encMagic)]) != encMagic || header[len(encMagic)] != encVersion {
+		return nil, errors.New("logging: unknown encrypted archive format")
+	}
+
+	p := header[len(encMagic)+1:]
+	wrapNonce, p := p[:kek.NonceSize()], p[kek.NonceSize():]
+	wrapped, prefix := p[:encKeySize+kek.Overhead()], p[encKeySize+kek.Overhead():]
+	dataKey, err := kek.Open(nil, wrapNonce, wrapped, []byte(encMagic))
+	if err != nil {
+		return nil, fmt.Errorf("logging: unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{src: src, aead: aead, prefix: prefix}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		var hdr [5]byte
+		if _, err := io.ReadFull(r.src, hdr[:]); err != nil {
+			return 0, errEncTruncated
+		}
+		n := binary.BigEndian.Uint32(hdr[1:])
+		if n > encChunkSize+uint32(r.aead.Overhead()) {
+			return 0, errors.New("logging: invalid encrypted chunk")
+		}
+		if cap(r.ct) < int(n) {
+			r.ct = make([]byte, n)
+		}
+		ct := r.ct[:n]
+		if _, err := io.ReadFull(r.src, ct); err != nil {
+			return 0, errEncTruncated
+		}
+
+		last := hdr[0] == 1
+		plain, err := r.aead.Open(ct[:0], chunkNonce(r.prefix, r.counter, last), ct, nil)
+		if err != nil {
+			return 0, fmt.Errorf("logging: decrypt chunk %d: %w", r.counter, err)
+		}
+		r.counter++
+		r.buf, r.done = plain, last
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,245 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotationEvent describes a log file rotation, delivered after the backup
+// file compressed.
+type RotationEvent struct {
+	Writer       string    // writer ID
+	LogFile      string    // the live log file
+	Backup       string    // file renamed from log file on rotation
+	Archive      string    // compressed archive, empty if compression failed
+	Size         int64     // size of backup file
+	ArchiveSize  int64     // size of compressed archive
+	RotatedAt    time.Time // when log file rotated
+	CompressedAt time.Time // when compression done
+	Err          error     // compression error
+}
+
+type rotationSubscriber struct {
+	ch        chan RotationEvent
+	closeOnce sync.Once
+}
+
+var (
+	rotationSubscribersLock sync.Mutex
+	rotationSubscribers     []*rotationSubscriber
+)
+
+// SubscribeRotations subscribe rotation events of all file writers, buffer is
+// the channel buffer size. If the subscriber too slow and buffer is full,
+// events dropped.
+//
+// Call cancel to unsubscribe, the channel closed after cancel. Calling cancel
+// more than once is OK.
+func SubscribeRotations(buffer int) (<-chan RotationEvent, func()) {
+	s := &rotationSubscriber{ch: make(chan RotationEvent, buffer)}
+
+	rotationSubscribersLock.Lock()
+	rotationSubscribers = append(rotationSubscribers, s)
+	rotationSubscribersLock.Unlock()
+
+	return s.ch, func() {
+		rotationSubscribersLock.Lock()
+		defer rotationSubscribersLock.Unlock()
+
+		for i, item := range rotationSubscribers {
+			if item == s {
+				rotationSubscribers = append(rotationSubscribers[:i], rotationSubscribers[i+1:]...)
+				break
+			}
+		}
+		s.closeOnce.Do(func() {
+			close(s.ch)
+		})
+	}
+}
+
+// Send event to all subscribers, never blocks.
+func publishRotation(e RotationEvent) {
+	rotationSubscribersLock.Lock()
+	defer rotationSubscribersLock.Unlock()
+
+	for _, s := range rotationSubscribers {
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
+
+// WithOnRotate calls fn after backup compressed, in the background worker
+// of rotation and startup recovery, never from Write. Backup is the file
+// renamed from the live log file, archive is the compressed archive, empty
+// if compression failed. Panics of fn recovered and reported as internal
+// error. Fn may call Purge, PurgeOlderThan, PurgeAll and CompressBackups of
+// the writer, run directly by fn instead of queued, other background work
+// waits fn returns.
+func WithOnRotate(fn func(backup, archive string)) Option {
+	return func(o *writerOptions) {
+		o.onRotate = fn
+	}
+}
+
+// WithRotationEvents sends RotationEvent of the writer to ch after each
+// backup compressed, or failed to, including backups compressed by startup
+// recovery. Sends never block the background worker, event dropped and
+// counted as FileStats.EventsDropped if ch is full, use a buffered channel.
+// Ch never closed by the writer.
+func WithRotationEvents(ch chan<- RotationEvent) Option {
+	return func(o *writerOptions) {
+		o.rotationEvents = ch
+	}
+}
+
+// Send event to rotation events channel, never blocks.
+func (w *fileLogWriter) sendRotation(e RotationEvent) {
+	if w.rotations == nil {
+		return
+	}
+
+	select {
+	case w.rotations <- e:
+	default:
+		atomic.AddInt64(&w.eventsDropped, 1)
+	}
+}
+
+// Call onRotate callback, recover its panic.
+func (w *fileLogWriter) callOnRotate(backup, archive string) {
+	if w.onRotate == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			w.reportError("callback", fmt.Errorf("OnRotate panic: %v", r))
+		}
+	}()
+	w.onRotate(backup, archive)
+}
+
+// DropEventInterval is the interval to aggregate dropped messages into
+// DropEvent.
+var DropEventInterval = 10 * time.Second
+
+// DropEvent reports messages dropped by a writer in an aggregate interval.
+type DropEvent struct {
+	Count   int64     // messages dropped in the interval
+	FirstAt time.Time // first drop in the interval
+	LastAt  time.Time // last drop in the interval
+	Sink    string    // ID of the writer dropped messages
+}
+
+type dropSubscriber struct {
+	ch        chan DropEvent
+	closeOnce sync.Once
+}
+
+var (
+	dropSubscribersLock sync.Mutex
+	dropSubscribers     []*dropSubscriber
+	dropAggregatorStop  chan struct{} // nil if aggregator not running
+)
+
+// SubscribeDrops subscribe drop events of all writers, events emitted by an
+// aggregator goroutine every DropEventInterval if there are messages dropped,
+// never from Write. If the subscriber too slow and buffer is full, events
+// dropped.
+//
+// Call cancel to unsubscribe, the channel closed after cancel. Calling cancel
+// more than once is OK.
+func SubscribeDrops(buffer int) (<-chan DropEvent, func()) {
+	s := &dropSubscriber{ch: make(chan DropEvent, buffer)}
+
+	dropSubscribersLock.Lock()
+	dropSubscribers = append(dropSubscribers, s)
+	if dropAggregatorStop == nil {
+		dropAggregatorStop = make(chan struct{})
+		go aggregateDrops(dropAggregatorStop)
+	}
+	dropSubscribersLock.Unlock()
+
+	return s.ch, func() {
+		dropSubscribersLock.Lock()
+		defer dropSubscribersLock.Unlock()
+
+		for i, item := range dropSubscribers {
+			if item == s {
+				dropSubscribers = append(dropSubscribers[:i], dropSubscribers[i+1:]...)
+				if len(dropSubscribers) == 0 {
+					close(dropAggregatorStop)
+					dropAggregatorStop = nil
+				}
+				break
+			}
+		}
+		s.closeOnce.Do(func() {
+			close(s.ch)
+		})
+	}
+}
+
+// Aggregator goroutine, compares dropped counters of writers on each tick.
+func aggregateDrops(stop chan struct{}) {
+	last := map[*writerCounters]int64{}
+	scan := func(publish bool) {
+		current := map[*writerCounters]int64{}
+		for _, w := range registeredWriters() {
+			c := w.counters()
+			dropped := atomic.LoadInt64(&c.dropped)
+			current[c] = dropped
+
+			prev, ok := last[c]
+			if !ok && !publish {
+				prev = dropped
+			}
+			first := atomic.SwapInt64(&c.firstDropAt, 0)
+			if dropped <= prev || !publish {
+				continue
+			}
+
+			lastAt := atomic.LoadInt64(&c.lastDropAt)
+			if first == 0 {
+				first = lastAt
+			}
+			publishDrop(DropEvent{
+				Count:   dropped - prev,
+				FirstAt: time.Unix(0, first),
+				LastAt:  time.Unix(0, lastAt),
+				Sink:    c.id,
+			})
+		}
+		last = current
+	}
+
+	scan(false)
+	ticker := time.NewTicker(DropEventInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			scan(true)
+		}
+	}
+}
+
+// Send event to all subscribers, never blocks.
+func publishDrop(e DropEvent) {
+	dropSubscribersLock.Lock()
+	defer dropSubscribersLock.Unlock()
+
+	for _, s := range dropSubscribers {
+		select {
+		case s.ch <- e:
+		default:
+		}
+	}
+}
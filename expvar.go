@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"expvar"
+	"sync"
+)
+
+var publishExpvarOnce sync.Once
+
+// PublishExpvar publish statistics of all writers created by this package to
+// expvar, under "logging" map. Calling PublishExpvar more than once is no-op.
+//
+// Values are computed on each read of expvar, from snapshot of writer
+// statistics.
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		m := expvar.NewMap("logging")
+		m.Set("written_bytes", expvar.Func(func() interface{} {
+			r := map[string]int64{}
+			for _, s := range AllWriterStats() {
+				r[s.ID] += s.Written
+			}
+			return r
+		}))
+		m.Set("file_size", expvar.Func(func() interface{} {
+			r := map[string]int64{}
+			for _, s := range AllWriterStats() {
+				if s.Kind == "file" {
+					r[s.ID] = s.FileSize
+				}
+			}
+			return r
+		}))
+		m.Set("dropped", sumStats(func(s WriterStats) int64 { return s.Dropped }))
+		m.Set("rotations", sumStats(func(s WriterStats) int64 { return s.Rotations }))
+		m.Set("compress_failures", sumStats(func(s WriterStats) int64 { return s.CompressFailures }))
+		m.Set("inner_errors", sumStats(func(s WriterStats) int64 { return s.InnerErrors }))
+		m.Set("queue_depth", sumStats(func(s WriterStats) int64 { return int64(s.QueueDepth) }))
+		m.Set("open_handles", expvar.Func(func() interface{} {
+			return OpenHandles()
+		}))
+		m.Set("writers", expvar.Func(func() interface{} {
+			return AllWriterStats()
+		}))
+	})
+}
+
+func sumStats(f func(s WriterStats) int64) expvar.Func {
+	return func() interface{} {
+		var r int64
+		for _, s := range AllWriterStats() {
+			r += f(s)
+		}
+		return r
+	}
+}
@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// FailoverWriter routes log records to primary writer while it is healthy,
+// and to secondary writer if primary keeps failing, such as ship logs to
+// network collector, and fallback to local rotated log file.
+//
+//  1. Record failed to write to primary are re-send to secondary, no record
+//     lost while primary failing but threshold not reached.
+//  2. If primary keeps failing longer than threshold, switch to secondary,
+//     primary is not tried until next probe.
+//  3. In secondary mode, every probe interval, try to write one record to
+//     primary, if succeed, switch back to primary.
+//
+// A notice line is emitted into the active writer on each switch. Write
+// returns error only if the record can not deliver to both writers.
+//
+// FailoverWriter is safe for concurrent use.
+type FailoverWriter struct {
+	// counters first, to keep 64-bit alignment for atomic operations
+	writerCounters
+	primaryRecords, secondaryRecords int64
+	primaryErrors, secondaryErrors   int64
+	switches                         int64
+
+	primary, secondary io.Writer
+	threshold          time.Duration
+	probeInterval      time.Duration
+
+	l            sync.Mutex
+	onSecondary  bool
+	failingSince time.Time // zero if primary is healthy
+	lastProbe    time.Time
+	lastErr      error // last primary error
+
+	secondaryFailure failureState
+}
+
+// FailoverStats is snapshot of FailoverWriter statistics.
+type FailoverStats struct {
+	PrimaryRecords   int64 // records written to primary
+	SecondaryRecords int64 // records written to secondary
+	PrimaryErrors    int64
+	SecondaryErrors  int64
+	Switches         int64 // times switched between primary and secondary
+	OnSecondary      bool  // true if secondary is the active writer
+}
+
+// NewFailoverWriter create a new FailoverWriter instance, see
+// WithFailoverThreshold and WithFailoverProbeInterval options.
+func NewFailoverWriter(primary, secondary io.Writer, opts ...Option) *FailoverWriter {
+	o := newWriterOptions(opts)
+	r := &FailoverWriter{
+		primary:       primary,
+		secondary:     secondary,
+		threshold:     o.failoverThreshold,
+		probeInterval: o.probeInterval,
+	}
+	r.id = writerID(o, "failover", "")
+	registerWriter(r, o)
+	return r
+}
+
+// Close primary and secondary writers if they are io.Closer.
+func (w *FailoverWriter) Close() error {
+	unregisterWriter(w)
+
+	var err error
+	for _, item := range []io.Writer{w.primary, w.secondary} {
+		if c, ok := item.(io.Closer); ok {
+			if e := c.Close(); err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// Write implements io.Writer interface.
+func (w *FailoverWriter) Write(p []byte) (n int, err error) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	now := hal.Now()
+	if !w.onSecondary || now.Sub(w.lastProbe) >= w.probeInterval {
+		if w.onSecondary {
+			w.lastProbe = now
+		}
+
+		if err = w.writePrimary(p); err == nil {
+			if w.onSecondary {
+				w.switchTo(false, now)
+				w.notice(w.primary, "primary writer recovered, switched back from secondary\n")
+			}
+			w.failingSince = time.Time{}
+			return len(p), nil
+		}
+
+		w.lastErr = err
+		if w.failingSince.IsZero() {
+			w.failingSince = now
+		}
+		if !w.onSecondary && now.Sub(w.failingSince) >= w.threshold {
+			w.switchTo(true, now)
+			w.notice(w.secondary, fmt.Sprintf("primary writer failing since %s: %s, switched to secondary\n",
+				w.failingSince.Format(time.RFC3339), err))
+		}
+	}
+
+	if err = w.writeSecondary(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *FailoverWriter) writePrimary(p []byte) error {
+	if _, err := w.primary.Write(p); err != nil {
+		atomic.AddInt64(&w.primaryErrors, 1)
+		atomic.AddInt64(&w.innerErrors, 1)
+		return err
+	}
+	atomic.AddInt64(&w.primaryRecords, 1)
+	atomic.AddInt64(&w.written, int64(len(p)))
+	return nil
+}
+
+func (w *FailoverWriter) writeSecondary(p []byte) error {
+	if _, err := w.secondary.Write(p); err != nil {
+		atomic.AddInt64(&w.secondaryErrors, 1)
+		atomic.AddInt64(&w.innerErrors, 1)
+		w.reportError("failover", err)
+		w.drop(1)
+		w.secondaryFailure.set(err)
+		return err
+	}
+	w.secondaryFailure.clear()
+	atomic.AddInt64(&w.secondaryRecords, 1)
+	atomic.AddInt64(&w.written, int64(len(p)))
+	return nil
+}
+
+func (w *FailoverWriter) switchTo(secondary bool, now time.Time) {
+	w.onSecondary = secondary
+	w.lastProbe = now
+	atomic.AddInt64(&w.switches, 1)
+}
+
+// Write transition notice to writer, notice is not counted as record, errors ignored.
+func (w *FailoverWriter) notice(dest io.Writer, msg string) {
+	_, _ = dest.Write([]byte(fmt.Sprintf("%s [%s] failover: %s", hal.Now().Format(`2006/01/02 15:04:05`), tag, msg)))
+}
+
+// Stats returns statistics snapshot.
+func (w *FailoverWriter) Stats() FailoverStats {
+	w.l.Lock()
+	onSecondary := w.onSecondary
+	w.l.Unlock()
+
+	return FailoverStats{
+		PrimaryRecords:   atomic.LoadInt64(&w.primaryRecords),
+		SecondaryRecords: atomic.LoadInt64(&w.secondaryRecords),
+		PrimaryErrors:    atomic.LoadInt64(&w.primaryErrors),
+		SecondaryErrors:  atomic.LoadInt64(&w.secondaryErrors),
+		Switches:         atomic.LoadInt64(&w.switches),
+		OnSecondary:      onSecondary,
+	}
+}
+
+func (w *FailoverWriter) stats() WriterStats {
+	return w.snapshot("failover", "")
+}
+
+func (w *FailoverWriter) workerStatus() string {
+	return fmt.Sprintf("on secondary: %v", w.Stats().OnSecondary)
+}
+
+// Broken if secondary failing, degraded if primary failing longer than
+// SinkDownThreshold.
+func (w *FailoverWriter) health() *Problem {
+	if p := w.secondaryFailure.problem(&w.writerCounters, "failover", "", Broken, 0); p != nil {
+		return p
+	}
+
+	w.l.Lock()
+	defer w.l.Unlock()
+	if w.failingSince.IsZero() || hal.Now().Sub(w.failingSince) < SinkDownThreshold {
+		return nil
+	}
+	return &Problem{w.id, "failover", "", Degraded, w.lastErr, w.failingSince}
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/redforks/hal"
+)
+
+// logFile is the live log file operations used by file log writer,
+// implemented by *os.File, can be replaced to simulate IO errors.
+type logFile interface {
+	io.WriteCloser
+	Name() string
+	Stat() (os.FileInfo, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// Opens live log file, replaced to simulate IO errors.
+var openLogFileFunc = func(path string, perm os.FileMode) (logFile, error) {
+	f, err := openLogFile(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Returned if reopen log file failed after rotation.
+var errLogFileNotOpen = errors.New("logging: log file not open")
+
+// max retries of interrupted or zero length writes
+const maxWriteRetries = 8
+
+// WithEmergencyPurge deletes oldest half of compressed archives if disk is
+// full, then retry the write once. Without it, or still full, records go to
+// stderr until the log file writable again, reported as Degraded health
+// problem.
+func WithEmergencyPurge() Option {
+	return func(o *writerOptions) {
+		o.emergencyPurge = true
+	}
+}
+
+// Stat the live log file, reopen it if deleted or replaced, such as by
+// `rm`, and resync size if truncated, such as by `truncate -s0`. Size is the
+// tracked size. Deleted or replaced detected by os.SameFile(), which compares
+// device and inode, or file index on windows. Must hold w.l.
+func (w *fileLogWriter) checkLiveFile(size int64) (os.FileInfo, error) {
+	w.writesSinceStat, w.lastStat = 0, hal.Now()
+	info, err := w.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	pathInfo, err := os.Stat(w.CurrentPath())
+	switch {
+	case os.IsNotExist(err) || err == nil && !os.SameFile(info, pathInfo):
+		w.reportError("file", fmt.Errorf("live log file %s deleted or replaced, reopened", w.CurrentPath()))
+		safeClose("file", w.f)
+		if w.f, err = w.openFile(w.CurrentPath()); err != nil {
+			return nil, err
+		}
+		if info, err = w.f.Stat(); err != nil {
+			return nil, err
+		}
+		w.resetLineCount()
+	case err != nil:
+		// can not tell, such as permission denied, keep writing
+	case info.Size() < size:
+		w.reportError("file", fmt.Errorf("live log file %s truncated from %d to %d bytes", w.CurrentPath(), size, info.Size()))
+		w.resetLineCount()
+	}
+	atomic.StoreInt64(&w.currentSize, info.Size())
+	if info.Size() == 0 && w.header != nil {
+		w.writeHeader()
+		if info, err = w.f.Stat(); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// Write all bytes of p, retry on short writes and EINTR.
+func (w *fileLogWriter) writeFull(p []byte) (n int, err error) {
+	retries := 0
+	for n < len(p) {
+		m, err := w.f.Write(p[n:])
+		n += m
+		switch {
+		case err == nil && m > 0:
+			retries = 0
+			continue
+		case err == nil:
+			err = io.ErrShortWrite
+		case !errors.Is(err, syscall.EINTR):
+			return n, err
+		}
+
+		if retries++; retries > maxWriteRetries {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func isNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// Handle disk full after n bytes of p written, returns error if not handled.
+// Must hold w.l.
+func (w *fileLogWriter) handleNoSpace(p []byte, n int, err error) (int, error) {
+	if w.emergencyPurge && w.secureDelete {
+		// overwrite may take long, records written to stderr until purged
+		w.enqueuePurgeForSpace()
+	} else if w.emergencyPurge && w.purgeForSpace() {
+		m, e := w.writeFull(p[n:])
+		if n, err = n+m, e; err == nil {
+			w.leaveStderrMode()
+			return n, nil
+		}
+		if !isNoSpace(err) {
+			return n, err
+		}
+	}
+
+	if atomic.CompareAndSwapInt32(&w.stderrMode, 0, 1) {
+		w.reportError("file", fmt.Errorf("disk full, write logs to stderr: %w", err))
+	}
+	w.spaceFailure.set(err)
+	if _, e := os.Stderr.Write(p[n:]); e != nil {
+		return n, err
+	}
+	return len(p), nil
+}
+
+// Leave stderr mode after the log file writable again. Must hold w.l.
+func (w *fileLogWriter) leaveStderrMode() {
+	if atomic.CompareAndSwapInt32(&w.stderrMode, 1, 0) {
+		w.spaceFailure.clear()
+	}
+}
+
+// Delete oldest half of compressed archives, at least one, returns false if
+// nothing deleted.
+func (w *fileLogWriter) purgeForSpace() bool {
+	files, err := w.getCompressedFiles(w.CurrentPath())
+	if err != nil {
+		w.reportError("retention", err)
+		return false
+	}
+
+	deleted := false
+	for _, f := range files[:(len(files)+1)/2] {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		if err = w.removeArchive(f); err != nil {
+			w.reportError("retention", err)
+			continue
+		}
+		removeSidecars(f)
+		deleted = true
+		w.audit.record(auditEntry{Action: auditDelete, File: f, Reason: reasonSpace, SizeBefore: info.Size()})
+		if debugEnabled() {
+			debugf("retention: disk full, deleted %s", f)
+		}
+	}
+	return deleted
+}
+
+// Queue purgeForSpace() to background worker, so writes not blocked by
+// secure delete of archives.
+func (w *fileLogWriter) enqueuePurgeForSpace() {
+	w.enqueueJob("space", func() {
+		w.purgeForSpace()
+	})
+}
@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+// Result of a write to fakeLogFile, n bytes written to the file then err
+// returned.
+type fakeWrite struct {
+	n   int
+	err error
+}
+
+// Scripted results of writes, shared by log files opened by the writer.
+type fakeWrites struct {
+	l       sync.Mutex
+	results []fakeWrite
+	calls   int
+}
+
+func (s *fakeWrites) set(results ...fakeWrite) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.results, s.calls = results, 0
+}
+
+func (s *fakeWrites) count() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.calls
+}
+
+// Log file returns scripted results of writes, writes to the file if run
+// out of results.
+type fakeLogFile struct {
+	*os.File
+	writes *fakeWrites
+}
+
+func (f *fakeLogFile) Write(p []byte) (int, error) {
+	s := f.writes
+	s.l.Lock()
+	s.calls++
+	if len(s.results) == 0 {
+		s.l.Unlock()
+		return f.File.Write(p)
+	}
+	r := s.results[0]
+	s.results = s.results[1:]
+	s.l.Unlock()
+
+	if r.n > len(p) {
+		r.n = len(p)
+	}
+	n, err := f.File.Write(p[:r.n])
+	if err != nil {
+		return n, err
+	}
+	return n, r.err
+}
+
+// Log files opened by writers wrapped by fakeLogFile of returned script,
+// restored on cleanup.
+func fakeLogFiles(t *testing.T) *fakeWrites {
+	t.Helper()
+	s := &fakeWrites{}
+	old := openLogFileFunc
+	openLogFileFunc = func(path string, perm os.FileMode) (logFile, error) {
+		f, err := openLogFile(path, perm)
+		if err != nil {
+			return nil, err
+		}
+		return &fakeLogFile{f, s}, nil
+	}
+	t.Cleanup(func() { openLogFileFunc = old })
+	return s
+}
+
+// Redirect stderr to a file, returns function reads content written so far,
+// restored on cleanup.
+func captureStderr(t *testing.T) func() string {
+	t.Helper()
+	f, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stderr
+	os.Stderr = f
+	t.Cleanup(func() {
+		os.Stderr = old
+		_ = f.Close()
+	})
+	return func() string {
+		content, err := ioutil.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+}
+
+var errNoSpace = &os.PathError{Op: "write", Path: "app.log", Err: syscall.ENOSPC}
+
+func TestWriteFull(t *testing.T) {
+	eintr := &os.PathError{Op: "write", Path: "app.log", Err: syscall.EINTR}
+	zeros := func(n int) []fakeWrite {
+		return make([]fakeWrite, n)
+	}
+	tests := []struct {
+		name    string
+		results []fakeWrite
+		n       int
+		err     error
+		calls   int
+	}{
+		{"written", nil, 6, nil, 1},
+		{"interrupted", []fakeWrite{{0, eintr}, {2, eintr}}, 6, nil, 3},
+		{"short writes", []fakeWrite{{2, nil}, {1, nil}, {2, nil}}, 6, nil, 4},
+		{"zero length writes", zeros(maxWriteRetries), 6, nil, maxWriteRetries + 1},
+		{"too many zero length writes", zeros(maxWriteRetries + 1), 0, io.ErrShortWrite, maxWriteRetries + 1},
+		{"too many interrupts", []fakeWrite{{1, nil}, {0, eintr}, {0, eintr}, {0, eintr}, {0, eintr},
+			{0, eintr}, {0, eintr}, {0, eintr}, {0, eintr}, {0, eintr}}, 1, syscall.EINTR, 10},
+		{"short write then error", []fakeWrite{{3, nil}, {1, errNoSpace}}, 4, syscall.ENOSPC, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeWrites{results: tt.results}
+			path := filepath.Join(t.TempDir(), "app.log")
+			f, err := openLogFile(path, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			w := &fileLogWriter{f: &fakeLogFile{f, s}}
+
+			n, err := w.writeFull([]byte("hello\n"))
+			if n != tt.n || tt.err == nil && err != nil || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("writeFull: %d, %v, want %d, %v", n, err, tt.n, tt.err)
+			}
+			if s.count() != tt.calls {
+				t.Errorf("%d writes, want %d", s.count(), tt.calls)
+			}
+			if content, _ := ioutil.ReadFile(path); string(content) != "hello\n"[:tt.n] {
+				t.Errorf("content %q", content)
+			}
+		})
+	}
+}
+
+// Disk full, archives purged and retried once if emergency purge, records
+// written to stderr if still full, until the log file writable again.
+func TestWriteNoSpace(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		results  []fakeWrite
+		stderr   bool
+		archives int
+		calls    int
+	}{
+		{"stderr", nil, []fakeWrite{{0, errNoSpace}}, true, 2, 1},
+		{"short write then stderr", nil, []fakeWrite{{2, errNoSpace}}, true, 2, 1},
+		{"purged", []Option{WithEmergencyPurge()}, []fakeWrite{{0, errNoSpace}}, false, 1, 2},
+		{"still full after purged", []Option{WithEmergencyPurge()}, []fakeWrite{{0, errNoSpace}, {0, errNoSpace}}, true, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakeLogFiles(t)
+			stderr := captureStderr(t)
+			w := newTestFileWriter(t, 0, 0, tt.opts...)
+			rotateTimes(t, w, 2)
+
+			s.set(tt.results...)
+			if n, err := w.Write([]byte("full\n")); err != nil || n != 5 {
+				t.Fatalf("write: %d, %v", n, err)
+			}
+			if s.count() != tt.calls {
+				t.Errorf("%d writes, want %d", s.count(), tt.calls)
+			}
+			if got := atomic.LoadInt32(&w.stderrMode) == 1; got != tt.stderr {
+				t.Errorf("stderr mode %v, want %v", got, tt.stderr)
+			}
+			// rest of the record not written to the log file
+			if got := strings.Contains(stderr(), "full\n"[tt.results[0].n:]); got != tt.stderr {
+				t.Errorf("record written to stderr %v, want %v", got, tt.stderr)
+			}
+			w.waitBackground()
+			if archives, _ := w.getCompressedFiles(w.CurrentPath()); len(archives) != tt.archives {
+				t.Errorf("%d archives, want %d", len(archives), tt.archives)
+			}
+
+			// writable again
+			if _, err := w.Write([]byte("ok\n")); err != nil {
+				t.Fatal(err)
+			}
+			if atomic.LoadInt32(&w.stderrMode) != 0 {
+				t.Error("stderr mode not left")
+			}
+			live, err := ioutil.ReadFile(w.CurrentPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := "full\nok\n"
+			if tt.stderr {
+				// written part of the record kept
+				want = "full\n"[:tt.results[0].n] + "ok\n"
+			}
+			if string(live) != want {
+				t.Errorf("live log file %q, want %q", live, want)
+			}
+		})
+	}
+}
@@ -1,164 +1,1222 @@
 package logging
 
 import (
-	"compress/gzip"
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/redforks/hal"
 )
 
+// default time layout of backup file names
+const backupTimeLayout = `2006-01-02-150405`
+
+// permission of created log files if not configured, see WithFileMode()
+const defaultFileMode os.FileMode = 0640
+
+// max writes and time between Stat of the live log file, var for testing
+var (
+	statInterval = 1000
+	statPeriod   = 10 * time.Second
+)
+
 // Log writer manage log files:
 //
 //  1. Create new file if log file length is too large
-//  2. Compress old log files (Done in its own goroutine).
+//  2. Compress old log files (Done in its background worker goroutine).
 //
-// fileLogWriter should wrapped in AsyncLogWriter, to prevent hurt log caller's
-// performance.
+// fileLogWriter is safe for concurrent use, Write, rotation and other
+// operations on the live log file serialized by an internal mutex. It should
+// wrapped in AsyncLogWriter, to prevent hurt log caller's performance.
 type fileLogWriter struct {
-	path     string // log file path
-	f        *os.File
+	writerCounters
+	background    int64  // queued background jobs, access by atomic
+	followDropped int64  // lines dropped by slow Follow() consumers, access by atomic
+	eventsDropped int64  // rotation events dropped by slow consumer, access by atomic
+	workerID      uint64 // goroutine id of the background worker, 0 if not running, access by atomic
+	stderrMode    int32  // 1 if disk full and records written to stderr, access by atomic
+
+	path string // log file path, resolved from pathTemplate if dated
+
+	pathTemplate string       // log file path with date placeholders, "" if not dated
+	pathL        sync.RWMutex // protects path if dated
+	pathCheckAt  time.Time    // next time to resolve pathTemplate
+
+	l        sync.Mutex // protects f, closed, purged and freeze state
+	f        logFile
 	maxLen   int64
 	maxFiles int
+
+	rotateEvery time.Duration // 0 if rotate by size only
+	openedAt    time.Time     // start of current rotation interval
+	calendar    *calendarRotation
+	periodStart time.Time // calendar period of the live log file
+
+	minRotateInterval time.Duration // min interval of size triggered rotations, 0 to disable
+	rotateSuppressed  bool          // size triggered rotation suppressed by minRotateInterval
+	rotateRetryAt     time.Time     // write path not rotate until, after rotation failed
+
+	lowDiskFree   int64         // min free bytes of log file system, 0 to disable
+	lowDiskAction LowDiskAction // action if free space below lowDiskFree
+	lowDisk       int32         // 1 if free space below lowDiskFree, access by atomic
+	diskCheckedAt time.Time     // time of last free space check
+
+	backupLayout   string // time layout of backup file names
+	backupAfterExt bool   // time appended after log file extension
+	backupUTC      bool   // backup file names use UTC time, suffixed by `Z`
+	backupHost     string // hostname inserted to backup file names, "" if disabled
+	backupPID      bool   // pid inserted to backup file names
+
+	backupName BackupNameFunc         // names backups instead of backupLayout, nil if not set
+	isBackup   func(name string) bool // matches backups named by backupName
+
+	compressor Compressor // compression of backups, nil for gzip
+	numbered   bool       // backups named by number, see WithNumberedBackups()
+	renumberL  sync.Mutex // serializes renumbering and compression of numbered backups
+
+	compressRate int64 // max bytes per second read by compression, 0 for unlimited
+
+	compressRetry compressRetries // failed compressions waiting for retry
+
+	keepUncompressed int  // most recent backups left uncompressed, see WithKeepUncompressed()
+	copyTruncate     bool // rotate by copy and truncate, see WithCopyTruncate()
+
+	maxLines  int64 // max lines of log file, 0 to disable
+	lineCount int64 // lines of the live log file, counted if maxLines > 0
+
+	compressOnClose bool  // final rotation on Close, see WithCompressOnClose()
+	aborting        int32 // 1 if closed by abort, no final rotation, access by atomic
+
+	manifest  bool       // maintain manifest of archives, see WithManifest()
+	manifestL sync.Mutex // serializes rewrites of manifest
+
+	failure      failureState
+	spaceFailure failureState // disk full, records written to stderr
+	closed       bool
+	audit        *auditLog
+	adopt        []adoptPattern
+	key          KeyFunc // encryption key of archives, nil if not encrypted
+	signKey      KeyFunc // signing key of archives, nil if not signed
+
+	secureDelete     bool
+	secureDeleteMax  int64
+	secureDeleteRate int64
+
+	fileMode    os.FileMode // 0 if not configured
+	uid, gid    int         // -1 if not configured
+	strictPerms bool
+	permDrift   failureState
+
+	maxTotalSize int64         // 0 if not limited
+	maxAge       time.Duration // 0 if not limited
+
+	lazyOpen bool // f opened on demand, and may closed by handle cache
+
+	currentLink       string // symlink to the live log file, "" if disabled
+	currentLinkFailed int32  // 1 if failed to update currentLink, access by atomic
+
+	header     HeaderFunc // written at top of new log files, nil if disabled
+	headerLen  int64      // bytes of header written to the live log file
+	archiveDir string     // directory of backups, "" for next to the live log file
+	onRotate   func(backup, archive string)
+	rotations  chan<- RotationEvent // rotation events of the writer, nil if disabled
+	bundleAge  time.Duration        // archives older than it packed to daily bundles, 0 to disable
+
+	jobsL        sync.Mutex
+	jobs         []backgroundJob // queued background jobs, protected by jobsL
+	jobsRunning  bool            // worker goroutine running, protected by jobsL
+	backgroundWG sync.WaitGroup  // the worker goroutine
+	closeTimeout time.Duration   // max wait of backgroundWG on Close
+
+	lock *os.File // locked sidecar file, see WithFileLock(), nil if not locked
+
+	recoverStop chan struct{} // stops reopening after write error, nil if not recovering
+
+	syncEvery int64         // fsync after bytes written, 0 to disable
+	unsynced  int64         // bytes written since last fsync
+	syncStop  chan struct{} // stops sync ticker, nil if no sync interval
+
+	buf       *bufio.Writer // buffers records to f, nil if not buffered
+	flushStop chan struct{} // stops flush ticker, nil if not buffered
+
+	writesSinceStat int       // writes since last Stat of f
+	lastStat        time.Time // time of last Stat of f
+
+	syncOnError    bool
+	emergencyPurge bool
+
+	// backups being compressed, true if deleted by purge meanwhile,
+	// compression output of them removed.
+	purged map[string]bool
+
+	frozen      chan struct{} // not nil if frozen, closed on thaw
+	frozenBuf   [][]byte      // records written while frozen
+	frozenBytes int
 }
 
 // NewFileLogWriter create a new instance fileLogWriter.
-// maxLen: If log file length greater than maxLen, a new log file created
-// maxFiles: Limits of archived files, old archived files will delete.
-func NewFileLogWriter(path string, maxLen int64, maxFiles int) (io.Writer, error) {
-	f, err := openLogFile(path)
+// maxLen: Rotate before log file length exceeds maxLen, a new log file
+// created. A record larger than maxLen written whole to its own file. Not
+// rotated by size if maxLen <= 0.
+// maxFiles: Limits of archived files, old archived files will delete. All
+// archives kept if maxFiles <= 0.
+//
+// The returned writer is safe for concurrent Write. Close it to release the
+// log file. Same as NewFileWriter() with WithMaxLen(maxLen) and
+// WithMaxFiles(maxFiles), opts applied after them.
+func NewFileLogWriter(path string, maxLen int64, maxFiles int, opts ...Option) (io.WriteCloser, error) {
+	return NewFileWriter(path, append([]Option{WithMaxLen(maxLen), WithMaxFiles(maxFiles)}, opts...)...)
+}
+
+// FileLogWriter is the file log writer created by NewFileWriter(), writer
+// returned by NewFileLogWriter() implements it too. All methods are safe to
+// call concurrently, with each other and with Write.
+type FileLogWriter interface {
+	io.WriteCloser
+
+	// Rotate rotates the live log file now, see Rotate().
+	Rotate() error
+
+	// Sync commits the log file to disk.
+	Sync() error
+
+	// Reopen reopens the log file path if renamed by external rotation, see
+	// Reopen().
+	Reopen() error
+
+	// Stats returns statistics of the log file, its archives and runtime
+	// counters.
+	Stats() (FileStats, error)
+
+	// ListBackups returns backups of the log file, oldest first, see
+	// ListBackups().
+	ListBackups() ([]BackupInfo, error)
+
+	// Purge deletes backups rotated before olderThan, see Purge().
+	Purge(olderThan time.Time) (int, error)
+
+	// CompressBackups compresses all uncompressed backups now, see
+	// CompressBackups().
+	CompressBackups() error
+
+	// CurrentPath returns path of the live log file, differs from the path
+	// passed to the constructor if fallback to a per pid log file, see
+	// WithFileLock(), or resolved from date placeholders.
+	CurrentPath() string
+}
+
+// NewFileWriter creates file log writer of path configured by opts, max
+// length of log file default to 256MB, and 5 archives kept, see WithMaxLen(),
+// WithMaxFiles(). Returns error if an option value invalid, or options
+// conflict.
+//
+// Directories of path may contain date placeholders, `%Y`, `%m`, `%d` and
+// `%H`, `%%` for a literal percent sign, such as
+// `/var/log/app/%Y-%m-%d/app.log`. Placeholders resolved by local time when
+// opened, the writer switches to the new path at the first write after the
+// resolved path changed, previous log file rotated as usual. Retention
+// counts archives of all the dated directories, empty ones removed. Path
+// without placeholders used as is.
+//
+// If write fails, such as NFS hiccup, the failure reported as internal error
+// once, the writer closes the log file and reopens it in background with
+// exponential backoff, records written meanwhile dropped and counted as
+// Dropped, Write not returns the error.
+//
+// The returned writer is safe for concurrent use. Close it to release the
+// log file.
+func NewFileWriter(path string, opts ...Option) (FileLogWriter, error) {
+	w, err := newFileLogWriter(path, opts)
 	if err != nil {
 		return nil, err
 	}
-	r := &fileLogWriter{path, f, maxLen, maxFiles}
+	return w, nil
+}
+
+func newFileLogWriter(path string, opts []Option) (*fileLogWriter, error) {
+	o := newWriterOptions(opts)
+	if err := validateFileOptions(path, o); err != nil {
+		return nil, err
+	}
+	maxLen, maxFiles := o.maxLen, o.maxFiles
+	if err := validateBackupLayout(o.backupLayout); err != nil {
+		return nil, err
+	}
+	if err := resolveCompressor(o); err != nil {
+		return nil, err
+	}
+	if o.encryptKey != nil {
+		if _, err := loadKey(o.encryptKey); err != nil {
+			return nil, err
+		}
+	}
+	if o.signKey != nil {
+		if _, err := loadSigningKey(o.signKey); err != nil {
+			return nil, err
+		}
+	}
+	var tmpl string
+	if hasPathPlaceholders(path) {
+		if err := validatePathTemplate(path); err != nil {
+			return nil, err
+		}
+		tmpl, path = path, resolvePathTemplate(path, hal.Now())
+	}
+	var lock *os.File
+	if o.lockPolicy != 0 {
+		var err error
+		if path, lock, err = lockLogFile(path, o.lockPolicy); err != nil {
+			return nil, err
+		}
+	}
+	r := &fileLogWriter{path: path, maxLen: maxLen, maxFiles: maxFiles, key: o.encryptKey, signKey: o.signKey}
+	r.lock = lock
+	r.id = writerID(o, "file", path)
+	if tmpl != "" {
+		r.pathTemplate, r.pathCheckAt = tmpl, nextPathCheck(hal.Now())
+		r.id = writerID(o, "file", tmpl)
+	}
+	r.adopt = o.adopt
+	r.syncOnError, r.emergencyPurge = o.syncOnError, o.emergencyPurge
+	r.secureDelete, r.secureDeleteMax, r.secureDeleteRate = o.secureDelete, o.secureDeleteMax, o.secureDeleteRate
+	r.fileMode, r.uid, r.gid, r.strictPerms = o.fileMode, o.uid, o.gid, o.strictPerms
+	r.lazyOpen = o.lazyOpen
+	r.rotateEvery, r.openedAt = o.rotateEvery, hal.Now()
+	r.minRotateInterval = o.minRotateInterval
+	r.lowDiskFree, r.lowDiskAction = o.lowDiskFree, o.lowDiskAction
+	r.calendar = o.calendar
+	r.backupLayout, r.backupAfterExt, r.backupUTC = o.backupLayout, o.backupAfterExt, o.backupUTC
+	if o.backupHost {
+		r.backupHost = backupHostname()
+	}
+	r.backupPID = o.backupPID
+	r.backupName, r.isBackup = o.backupName, o.isBackup
+	r.numbered, r.compressor, r.compressRate = o.numbered, o.compressor, o.compressRate
+	r.keepUncompressed, r.copyTruncate = o.keepUncompressed, o.copyTruncate
+	r.maxLines, r.compressOnClose = o.maxLines, o.compressOnClose
+	r.manifest = o.manifest
+	r.maxTotalSize, r.maxAge = o.maxTotalSize, o.maxAge
+	r.header = o.header
+	r.archiveDir, r.onRotate, r.rotations = o.archiveDir, o.onRotate, o.rotationEvents
+	r.bundleAge = o.bundleAge
+	r.closeTimeout = o.closeTimeout
+	r.syncEvery = o.syncEvery
+	if r.backupName != nil {
+		if err := r.validateBackupNameFunc(path); err != nil {
+			unlockLogFile(r.lock)
+			return nil, err
+		}
+	}
+	if r.archiveDir != "" {
+		if err := r.ensureArchiveDir(); err != nil {
+			unlockLogFile(r.lock)
+			return nil, err
+		}
+	}
+	if o.currentLinkOn {
+		r.currentLink = o.currentLink
+		if r.currentLink == "" {
+			r.currentLink = r.linkBase() + currentLinkSuffix
+		}
+	}
+
+	if !r.lazyOpen {
+		f, err := r.openFile(path)
+		if err != nil {
+			unlockLogFile(r.lock)
+			return nil, err
+		}
+		r.f = f
+	}
+	r.audit = newAuditLog(r.id, path, o.auditMaxSize)
+	r.lastStat = hal.Now()
+	info, err := os.Stat(path)
+	if err == nil {
+		atomic.StoreInt64(&r.currentSize, info.Size())
+		r.checkPerms(info)
+	}
+	// header lines not counted
+	r.resetLineCount()
+	r.writeHeader()
+	if r.calendar != nil {
+		// existing log file belongs to the period it last written
+		t := r.openedAt
+		if err == nil && info.Size() > 0 {
+			t = info.ModTime()
+		}
+		r.periodStart = r.calendar.start(t)
+	}
 	r.recoverPartialCompressFiles(path)
+	for _, p := range r.datedPaths()[1:] {
+		r.recoverPartialCompressFiles(p)
+	}
+	if r.maxAge > 0 {
+		r.enqueueJob("age", func() {
+			if err := r.cleanByAge(); err != nil {
+				r.reportError("retention", err)
+			}
+		})
+	}
+	// existing log file may exceed maxLen, such as killed before rotation
+	if o.freshStart || r.sizeDue(atomic.LoadInt64(&r.currentSize)) || r.linesDue() {
+		if err := r.rotateExisting(); err != nil {
+			if r.f != nil {
+				safeClose("file", r.f)
+			}
+			unlockLogFile(r.lock)
+			return nil, err
+		}
+	}
+	if o.bufferSize > 0 {
+		r.buf = bufio.NewWriterSize(bufferTarget{r}, o.bufferSize)
+		r.flushStop = make(chan struct{})
+		go r.runFlushTicker(o.flushInterval, r.flushStop)
+	}
+	if o.syncInterval > 0 {
+		r.syncStop = make(chan struct{})
+		go r.runSyncTicker(o.syncInterval, r.syncStop)
+	}
+	registerWriter(r, o)
 	return r, nil
 }
 
 func (w *fileLogWriter) recoverPartialCompressFiles(path string) {
+	w.removeOrphanTemps(path)
 	unCompressed, err := w.getUncompressedFiles(path)
 	if err != nil {
-		logError(err)
+		w.reportError("compress", err)
 		return
 	}
+	if debugEnabled() {
+		debugf("recover: uncompressed backups of %s: %v", path, unCompressed)
+	}
+	if w.numbered {
+		// renumbering must be serialized, one goroutine recovers all
+		w.recoverNumbered(path)
+		unCompressed = nil
+	} else if w.keepUncompressed > 0 {
+		w.enqueueJob("", func() {
+			if err := w.compressOutsideWindow(path); err != nil {
+				w.reportError("compress", err)
+			}
+		})
+		unCompressed = nil
+	}
 
 	for _, item := range unCompressed {
-		go func(f string) {
-			if err := w.compress(f); err != nil {
-				logError(err)
+		f := item
+		w.enqueueJob("", func() {
+			info, err := os.Stat(f)
+			if err != nil {
+				w.reportError("compress", err)
+				return
+			}
+			w.audit.record(auditEntry{Action: auditRecover, File: f, SizeBefore: info.Size()})
+			if err := w.archive(f, info.ModTime(), info.Size()); err != nil {
+				w.reportError("compress", err)
 			}
-		}(item)
+		})
+	}
+
+	if w.key != nil {
+		w.recoverPlainArchives(path)
+	}
+	// queued after recovery compressions, retention holds even if never
+	// rotated, such as crashed often on a quiet service
+	w.enqueueCleanup(path)
+}
+
+// Delete temp files of compression, encryption and signing, left by crash.
+func (w *fileLogWriter) removeOrphanTemps(path string) {
+	if w.backupName != nil {
+		temps, err := w.getNamedTemps(path)
+		if err != nil {
+			w.reportError("compress", err)
+		}
+		for _, f := range temps {
+			if debugEnabled() {
+				debugf("recover: remove orphan temp file %s", f)
+			}
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				w.reportError("compress", err)
+			}
+		}
+	}
+	// temp files of daily bundles too
+	prefix, _ := w.backupNameParts(path)
+	patterns := []string{globEscape(prefix) + `*` + bundleSuffix + `.tmp`}
+	for _, suffix := range w.archiveSuffixes() {
+		patterns = append(patterns,
+			w.backupPattern(path, suffix+`*.tmp`),
+			globEscape(w.archivePath(path))+`.*`+suffix+`*.tmp`)
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			w.reportError("compress", err)
+			continue
+		}
+		for _, f := range matches {
+			if debugEnabled() {
+				debugf("recover: remove orphan temp file %s", f)
+			}
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				w.reportError("compress", err)
+			}
+		}
+	}
+}
+
+// Encrypt archives left plain text, such as crashed before encryption.
+func (w *fileLogWriter) recoverPlainArchives(path string) {
+	var plain []string
+	for _, suffix := range w.archiveSuffixes() {
+		files, err := w.getBackFiles(path, suffix, false)
+		if err != nil {
+			w.reportError("encrypt", err)
+			return
+		}
+		plain = append(plain, files...)
+	}
+
+	for _, item := range plain {
+		f := item
+		w.enqueueJob("", func() {
+			if w.numbered {
+				w.renumberL.Lock()
+				defer w.renumberL.Unlock()
+			}
+			w.audit.record(auditEntry{Action: auditRecover, File: f})
+			if err := w.encryptArchive(f); err != nil {
+				w.reportError("encrypt", err)
+				return
+			}
+			w.auditEncrypted(f)
+			if w.signKey != nil {
+				if err := w.signArchive(f + encSuffix); err != nil {
+					w.reportError("sign", err)
+				}
+			}
+		})
+	}
+}
+
+func (w *fileLogWriter) auditEncrypted(archive string) {
+	if w.audit != nil {
+		w.audit.record(auditEntry{Action: auditEncrypt, File: archive, Target: archive + encSuffix,
+			Checksum: fileChecksum(archive + encSuffix)})
 	}
 }
 
 func (w *fileLogWriter) Write(p []byte) (n int, err error) {
-	if n, err = w.f.Write(p); err != nil {
-		return
+	return w.write(p, w.syncOnError && ParseLevel(p) >= LevelError)
+}
+
+// Write and fsync, for records need to be synced, see WithSyncOnError().
+func (w *fileLogWriter) writeSynced(p []byte) error {
+	_, err := w.write(p, true)
+	return err
+}
+
+// CurrentPath returns path of the live log file.
+func (w *fileLogWriter) CurrentPath() string {
+	if w.pathTemplate == "" {
+		return w.path
 	}
+	w.pathL.RLock()
+	defer w.pathL.RUnlock()
+	return w.path
+}
 
-	size, err := w.fileSize()
-	if err != nil {
-		return
+// Sync commits the log file to disk.
+func (w *fileLogWriter) Sync() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
 	}
+	if w.f == nil {
+		if w.lazyOpen {
+			// handle closed, nothing to sync
+			return nil
+		}
+		return errLogFileNotOpen
+	}
+	return w.syncLocked()
+}
 
-	if size >= w.maxLen {
-		fname := w.f.Name()
-		if err = w.f.Close(); err != nil {
+func (w *fileLogWriter) write(p []byte, sync bool) (n int, err error) {
+	w.l.Lock()
+
+	if w.closed {
+		w.l.Unlock()
+		return 0, ErrWriterClosed
+	}
+	if w.frozen != nil {
+		w.freezeRecord(p)
+		w.l.Unlock()
+		return len(p), nil
+	}
+	if w.recoverStop != nil {
+		// reopening the log file after write error
+		w.drop(1)
+		w.l.Unlock()
+		return len(p), nil
+	}
+	if n, err = w.writeLocked(p, sync); err != nil {
+		w.enterRecoveryLocked(err)
+		if n < len(p) {
+			// not dropped if failed after written, such as rotation
+			w.drop(1)
+		}
+		n, err = len(p), nil
+	}
+	w.l.Unlock()
+
+	if w.lazyOpen {
+		evictHandles(w)
+	}
+	return
+}
+
+// Write p to the live log file, rotate if needed. Must hold w.l.
+func (w *fileLogWriter) writeLocked(p []byte, sync bool) (n int, err error) {
+	defer func() {
+		if err != nil {
+			w.failure.set(err)
+		} else {
+			w.failure.clear()
+		}
+	}()
+
+	if err = w.ensureOpen(); err != nil {
+		return 0, err
+	}
+	if w.pathTemplate != "" && w.rotateAllowed() {
+		if err = w.switchDatedPathLocked(); err != nil && !w.rotateFailedLocked(err) {
+			return 0, err
+		}
+	}
+	if w.lowDiskFree > 0 && w.checkLowDisk() {
+		w.drop(1)
+		return len(p), nil
+	}
+	var lines int64
+	if w.maxLines > 0 {
+		lines = int64(bytes.Count(p, newline))
+	}
+	if err = w.rotateBeforeLocked(len(p), lines); err != nil {
+		return 0, err
+	}
+	if w.buf != nil {
+		n, err = w.writeBuffered(p)
+	} else if n, err = w.writeFull(p); isNoSpace(err) {
+		if n, err = w.handleNoSpace(p, n, err); err == nil && atomic.LoadInt32(&w.stderrMode) == 1 {
 			return
 		}
-		bakFile := w.newBackupFilename(fname)
-		if err = os.Rename(fname, w.newBackupFilename(fname)); err != nil {
+	} else if err == nil {
+		w.leaveStderrMode()
+	}
+	atomic.AddInt64(&w.written, int64(n))
+	if err == nil {
+		if sync {
+			err = w.syncLocked()
+		} else {
+			err = w.countUnsynced(n)
+		}
+	}
+	if err != nil {
+		atomic.AddInt64(&w.innerErrors, 1)
+		return
+	}
+
+	// the writer is the only one appending, Stat only periodically and
+	// before rotation, for external truncation and permission drift.
+	size := atomic.AddInt64(&w.currentSize, int64(n))
+	w.lineCount += lines
+	w.writesSinceStat++
+	if w.writesSinceStat >= statInterval || w.sizeDue(size) || hal.Now().Sub(w.lastStat) >= statPeriod {
+		// file size includes buffered records
+		if err = w.flushLocked(); err != nil {
 			return
 		}
-		if w.f, err = openLogFile(fname); err != nil {
+		var info os.FileInfo
+		if info, err = w.checkLiveFile(size); err != nil {
 			return
 		}
+		size = info.Size()
+		w.checkPerms(info)
+	}
+	if debugEnabled() {
+		debugf("write: %s size %d, maxLen %d", w.CurrentPath(), size, w.maxLen)
+	}
 
-		go func() {
-			if err := w.compress(bakFile); err != nil {
-				logError(err)
+	var now time.Time
+	intervalDue := false
+	if w.rotateEvery > 0 {
+		now = hal.Now()
+		intervalDue = now.Sub(w.openedAt) >= w.rotateEvery
+	}
+	limitDue := w.sizeDue(size) || w.linesDue()
+	if !intervalDue && limitDue && w.rotateTooSoon(hal.Now()) {
+		return
+	}
+	if (limitDue || intervalDue) && w.rotateAllowed() {
+		if now.IsZero() {
+			now = hal.Now()
+		}
+		bakFile := w.newBackupFilename(w.f.Name())
+		if debugEnabled() {
+			if intervalDue {
+				debugf("rotate: %s opened at %s, reached interval %s, backup to %s", w.CurrentPath(), w.openedAt, w.rotateEvery, bakFile)
+			} else if w.linesDue() {
+				debugf("rotate: %s lines %d reached maxLines %d, backup to %s", w.CurrentPath(), w.lineCount, w.maxLines, bakFile)
 			} else {
-				if err := w.cleanOldBackupFiles(fname); err != nil {
-					logError(err)
-				}
+				debugf("rotate: %s size %d reached maxLen %d, backup to %s", w.CurrentPath(), size, w.maxLen, bakFile)
 			}
-		}()
+		}
+		if err = w.rotateLocked(now, bakFile, size); w.rotateFailedLocked(err) {
+			err = nil
+		}
 	}
 	return
 }
 
-func (w *fileLogWriter) compress(logFile string) error {
-	gzfile := logFile + `.gz`
-	f, err := os.Create(gzfile)
-	if err != nil {
-		return err
+// Rotate by calendar, size and lines before writing n bytes of lines. Must
+// hold w.l.
+func (w *fileLogWriter) rotateBeforeLocked(n int, lines int64) error {
+	if !w.rotateAllowed() {
+		return nil
+	}
+	err := w.rotateCalendarLocked()
+	if err == nil {
+		err = w.rotateBeforeWriteLocked(n)
 	}
-	defer safeClose(f)
+	if err == nil && w.maxLines > 0 {
+		err = w.rotateBeforeLinesLocked(lines)
+	}
+	if w.rotateFailedLocked(err) {
+		return nil
+	}
+	return err
+}
 
-	src, err := os.Open(logFile)
-	if err != nil {
+// Returns true if the live log file of size reached maxLen, false if not
+// rotate by size.
+func (w *fileLogWriter) sizeDue(size int64) bool {
+	return w.maxLen > 0 && size >= w.maxLen
+}
+
+// Rotate if writing n bytes would exceed maxLen, so the log file never
+// exceeds maxLen, except a record larger than maxLen written whole to the
+// fresh file. Must hold w.l.
+func (w *fileLogWriter) rotateBeforeWriteLocked(n int) error {
+	size := atomic.LoadInt64(&w.currentSize)
+	if w.maxLen <= 0 || size <= w.headerLen || size+int64(n) <= w.maxLen {
+		return nil
+	}
+	if w.rotateTooSoon(hal.Now()) {
+		return nil
+	}
+
+	bakFile := w.newBackupFilename(w.f.Name())
+	if debugEnabled() {
+		debugf("rotate: %s size %d, write %d bytes would exceed maxLen %d, backup to %s", w.CurrentPath(), size, n, w.maxLen, bakFile)
+	}
+	return w.rotateLocked(hal.Now(), bakFile, size)
+}
+
+// Rename live log file to bakFile and reopen, compress bakFile and clean
+// old backups in background. Must hold w.l.
+func (w *fileLogWriter) rotateLocked(now time.Time, bakFile string, size int64) error {
+	return w.rotateToLocked(now, bakFile, size, w.f.Name())
+}
+
+// Rename live log file to bakFile and open next as the live log file, next
+// differs from the live log file if the dated path changed. Must hold w.l.
+func (w *fileLogWriter) rotateToLocked(now time.Time, bakFile string, size int64, next string) (err error) {
+	// never overwrite backup of an earlier rotation in the same second
+	bakFile = w.uniqueBackupName(w.f.Name(), bakFile)
+	var start time.Time
+	inst := instrumentation()
+	if inst != nil {
+		start = time.Now()
+	}
+
+	fname := w.f.Name()
+	if err = w.flushLocked(); err != nil {
+		w.reportError("file", err)
+	}
+	if w.copyTruncate && next == fname {
+		if err = w.copyTruncateLocked(bakFile); err != nil {
+			return &rotateError{err}
+		}
+	} else {
+		if err = w.f.Close(); err != nil {
+			return
+		}
+		if err = moveBackup(fname, bakFile); err != nil {
+			return w.reopenLiveLocked(fname, err)
+		}
+		if w.f, err = w.openFile(next); err != nil {
+			return
+		}
+	}
+	w.audit.record(auditEntry{Action: auditRotate, File: fname, Target: bakFile, SizeBefore: size})
+	w.rotated()
+	w.openedAt = now
+	atomic.StoreInt64(&w.currentSize, 0)
+	w.lineCount = 0
+	if next != fname {
+		// may exist, such as restarted in the same day
+		if info, err := w.f.Stat(); err == nil {
+			atomic.StoreInt64(&w.currentSize, info.Size())
+		}
+		if w.maxLines > 0 {
+			w.lineCount, _ = countFileLines(next)
+		}
+	}
+	w.writeHeader()
+	if inst != nil {
+		inst.Rotated(w.stats(), time.Since(start))
+	}
+
+	w.enqueueJob("", func() {
+		w.waitThawed()
+		var err error
+		switch {
+		case w.numbered:
+			err = w.archiveNumbered(fname)
+		case w.keepUncompressed > 0:
+			err = w.compressOutsideWindow(fname)
+		default:
+			err = w.archive(bakFile, now, size)
+		}
+		if err != nil {
+			w.reportError("compress", err)
+		}
+		// backups not compressed counted by retention as well
+		w.enqueueCleanup(fname)
+	})
+	return
+}
+
+// Rotate log file left by last run, see WithFreshStart(), or exceeds maxLen.
+func (w *fileLogWriter) rotateExisting() error {
+	size := atomic.LoadInt64(&w.currentSize)
+	if size == 0 {
+		return nil
+	}
+
+	w.l.Lock()
+	defer w.l.Unlock()
+	if err := w.ensureOpen(); err != nil {
 		return err
 	}
-	defer safeClose(src)
+	bakFile := w.newBackupFilename(w.f.Name())
+	if debugEnabled() {
+		debugf("rotate: %s on start, size %d, backup to %s", w.CurrentPath(), size, bakFile)
+	}
+	return w.rotateLocked(hal.Now(), bakFile, size)
+}
 
-	dest := gzip.NewWriter(f)
-	_, err = io.Copy(dest, src)
+// Compress backup file, update statistics and publish rotation event.
+func (w *fileLogWriter) archive(bakFile string, rotatedAt time.Time, size int64) error {
+	if w.compressionPaused() {
+		if debugEnabled() {
+			debugf("compress: low disk space, leave %s uncompressed", bakFile)
+		}
+		return nil
+	}
+	acquireCompressSlot()
+	defer releaseCompressSlot()
+
+	var start time.Time
+	inst := instrumentation()
+	if inst != nil {
+		start = time.Now()
+	}
+
+	var debugStart time.Time
+	if debugEnabled() {
+		debugStart = time.Now()
+		debugf("compress: start %s", bakFile)
+	}
+	w.startCompress(bakFile)
+	err := w.compress(bakFile)
+	if debugEnabled() {
+		debugf("compress: finish %s in %s, error: %v", bakFile, time.Since(debugStart), err)
+	}
+	compressed := bakFile + w.archiveCompressor().Suffix()
+	if w.takePurged(bakFile) {
+		w.removePurged(compressed)
+		w.removePurged(bakFile)
+		w.compressSucceeded(bakFile)
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		w.compressFailed(bakFile, rotatedAt)
+	} else {
+		w.compressSucceeded(bakFile)
+	}
+	w.auditCompressed(bakFile, compressed, size, err)
+	encrypted := false
+	if err == nil && w.key != nil {
+		if err = w.encryptArchive(compressed); err != nil {
+			err = fmt.Errorf("encrypt %s: %w", compressed, err)
+		} else {
+			encrypted = true
+			w.auditEncrypted(compressed)
+		}
+	}
+	if err == nil && w.signKey != nil {
+		archive := compressed
+		if encrypted {
+			archive += encSuffix
+		}
+		if err = w.signArchive(archive); err != nil {
+			err = fmt.Errorf("sign %s: %w", archive, err)
+		}
+	}
 	if err != nil {
-		return err
+		atomic.AddInt64(&w.compressFailures, 1)
 	}
-	if err := dest.Close(); err != nil {
-		return err
+	if inst != nil {
+		inst.Compressed(w.stats(), time.Since(start), err)
+	}
+
+	e := RotationEvent{
+		Writer:       w.id,
+		LogFile:      w.CurrentPath(),
+		Backup:       bakFile,
+		Size:         size,
+		RotatedAt:    rotatedAt,
+		CompressedAt: hal.Now(),
+		Err:          err,
 	}
-	return os.Remove(logFile)
+	if err == nil {
+		e.Archive = compressed
+		if encrypted {
+			e.Archive += encSuffix
+		}
+		if info, err := os.Stat(e.Archive); err == nil {
+			e.ArchiveSize = info.Size()
+		}
+		w.manifestAdded(bakFile, e.Archive, size)
+	}
+	publishRotation(e)
+	w.sendRotation(e)
+	w.callOnRotate(bakFile, e.Archive)
+	return err
 }
 
-func safeClose(f io.Closer) {
-	if err := f.Close(); err != nil {
-		logError(err)
+func (w *fileLogWriter) auditCompressed(bakFile, compressed string, size int64, err error) {
+	if w.audit == nil {
+		return
 	}
+
+	entry := auditEntry{Action: auditCompress, File: bakFile, SizeBefore: size, Error: errorString(err)}
+	if err == nil {
+		entry.Target = compressed
+		if info, err := os.Stat(entry.Target); err == nil {
+			entry.SizeAfter = info.Size()
+		}
+		entry.Checksum = fileChecksum(entry.Target)
+	}
+	w.audit.record(entry)
 }
 
-func logError(err error) {
-	s := err.Error()
-	_, _ = os.Stderr.Write([]byte(s))
+// Close writes records buffered while frozen, fsyncs and closes the log file,
+// then waits background compression and retention to finish, at most the
+// timeout of WithCloseTimeout(). Write after Close returns ErrWriterClosed.
+// Calling Close more than once is no-op.
+func (w *fileLogWriter) Close() error {
+	w.l.Lock()
+	if w.closed {
+		w.l.Unlock()
+		return nil
+	}
+	if w.compressOnClose && atomic.LoadInt32(&w.aborting) == 0 {
+		if err := w.finalRotateLocked(); err != nil {
+			w.reportError("rotate", err)
+		}
+	}
+	err := w.closeLocked()
+	w.l.Unlock()
+
+	w.waitBackground()
+	return err
 }
 
+// Close the log file, must hold w.l.
+func (w *fileLogWriter) closeLocked() error {
+	if w.frozen != nil {
+		w.thawLocked(w.frozen)
+	}
+	w.closed = true
+	if w.syncStop != nil {
+		close(w.syncStop)
+	}
+	if w.flushStop != nil {
+		close(w.flushStop)
+	}
+	if w.recoverStop != nil {
+		close(w.recoverStop)
+		w.recoverStop = nil
+	}
+	w.compressRetry.stop()
+	unregisterWriter(w)
+	if w.lazyOpen {
+		handleClosed(w)
+	}
+	defer func() {
+		unlockLogFile(w.lock)
+		w.lock = nil
+	}()
+	if w.f == nil {
+		return nil
+	}
+	err := w.syncLocked()
+	if e := w.f.Close(); err == nil {
+		err = e
+	}
+	w.f = nil
+	return err
+}
+
+// Compress to a temp file then rename, an archive exists is always complete.
+func (w *fileLogWriter) compress(logFile string) error {
+	c := w.archiveCompressor()
+	archive := logFile + c.Suffix()
+	tmp := archive + `.tmp`
+	info, err := os.Stat(logFile)
+	if err != nil {
+		return err
+	}
+	if cc, ok := c.(*codec); ok {
+		err = cc.compress(logFile, tmp, w.compressRate)
+	} else {
+		err = c.Compress(logFile, tmp)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	// keep the backup if the archive unreadable, such as disk error
+	// surfaced only at fsync, compressed again on recovery
+	if codecBySuffix(c.Suffix()) != nil {
+		if _, err := verifyArchive(context.Background(), tmp, nil, w.compressRate, true); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("logging: verify archive of %s: %w", logFile, err)
+		}
+	}
+	// archives readable as the live log file, not by umask of compressor
+	if err := os.Chmod(tmp, w.createMode()); err != nil {
+		w.reportError("compress", err)
+	}
+	// archives ordered by modification time as backups, such as by `ls -t`
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		w.reportError("compress", err)
+	}
+	if err := os.Rename(tmp, archive); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(archive))
+	// partial archive of other compressor, such as crashed before compressor
+	// changed
+	for _, suffix := range w.archiveSuffixes() {
+		if suffix != c.Suffix() {
+			if err := os.Remove(logFile + suffix); err != nil && !os.IsNotExist(err) {
+				w.reportError("compress", err)
+			}
+		}
+	}
+	// compressor may rename src, such as a no-op compressor
+	if err := os.Remove(logFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Delete old backups by maxFiles, maxAge and maxTotalSize. Backups not
+// compressed yet counted as well, so backups not pile up if compression
+// keeps failing.
 func (w *fileLogWriter) cleanOldBackupFiles(logfilename string) error {
-	files, err := w.getCompressedFiles(logfilename)
+	// newest first
+	archives, err := w.listArchives()
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i < len(files)-w.maxFiles; i++ {
-		if err = os.Remove(files[i]); err != nil {
+	if debugEnabled() {
+		debugf("retention: %d backups of %s, maxFiles %d", len(archives), logfilename, w.maxFiles)
+	}
+
+	for i := len(archives) - 1; w.maxFiles > 0 && i >= w.maxFiles; i-- {
+		a := archives[i]
+		if !a.Compressed {
+			w.markPurged(a.Path)
+		}
+		if err = w.deleteBackup(a.Path, reasonCount, a.Size); err != nil {
+			return err
+		}
+	}
+
+	if w.maxAge > 0 {
+		if err = w.cleanByAge(); err != nil {
 			return err
 		}
 	}
+	if w.maxTotalSize > 0 {
+		return w.cleanBySize()
+	}
 	return nil
 }
 
+// Delete backup and its sidecars by retention.
+func (w *fileLogWriter) deleteBackup(f, reason string, size int64) error {
+	if err := w.removeArchive(f); err != nil {
+		return err
+	}
+	removeSidecars(f)
+	w.audit.record(auditEntry{Action: auditDelete, File: f, Reason: reason, SizeBefore: size})
+	if debugEnabled() {
+		debugf("retention: deleted %s, reason %s", f, reason)
+	}
+	return nil
+}
+
+// Returns compressed backups of all recognized compressors, encrypted and
+// adopted backups included.
 func (w *fileLogWriter) getCompressedFiles(logfilename string) ([]string, error) {
-	return w.getBackFiles(logfilename, `.gz`)
+	var files []string
+	for i, suffix := range w.archiveSuffixes() {
+		// adopted backups listed once, regardless of compressor
+		compressed, err := w.getBackFiles(logfilename, suffix, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := w.getBackFiles(logfilename, suffix+encSuffix, false)
+		if err != nil {
+			return nil, err
+		}
+		files = append(append(files, compressed...), encrypted...)
+	}
+	w.sortBackups(logfilename, files)
+	return files, nil
 }
 
+// Returns native backups not compressed yet, adopted backups never compressed.
 func (w *fileLogWriter) getUncompressedFiles(logfilename string) ([]string, error) {
-	return w.getBackFiles(logfilename, ``)
+	return w.getBackFiles(logfilename, ``, false)
 }
 
-func (w *fileLogWriter) getBackFiles(logfilename, suffix string) ([]string, error) {
-	base, ext := w.splitLogFilename(logfilename)
-	files, err := filepath.Glob(base + `-*` + ext + suffix)
+// Returns backups ordered by rotation time, oldest first. Backups named by
+// both time and number included, whichever naming used by the writer.
+func (w *fileLogWriter) getBackFiles(logfilename, suffix string, adopted bool) ([]string, error) {
+	files, err := w.getTimestampFiles(logfilename, suffix)
 	if err != nil {
 		return nil, err
 	}
-	sort.Strings(files)
+	numbered, err := w.getNumberedFiles(logfilename, suffix)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, numbered...)
+	if adopted {
+		foreign, err := w.getAdoptedFiles(logfilename, suffix != ``)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, foreign...)
+	}
+
+	if w.currentLink != "" {
+		// never treat the link as backup, such as matched by adopt pattern
+		for i, f := range files {
+			if f == w.currentLink {
+				files = append(files[:i], files[i+1:]...)
+				break
+			}
+		}
+	}
+
+	w.sortBackups(logfilename, files)
+	return files, nil
+}
+
+// Returns backups named by rotation time, not sorted.
+func (w *fileLogWriter) getTimestampFiles(logfilename, suffix string) ([]string, error) {
+	if w.backupName != nil {
+		return w.getNamedBackups(logfilename, suffix)
+	}
+	matches, err := filepath.Glob(w.backupPattern(logfilename, globEscape(suffix)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := matches[:0]
+	for _, f := range matches {
+		if isSidecar(f) {
+			continue
+		}
+		// pattern without extension also matches backups with other
+		// suffix, such as compressed backups
+		if w.backupAfterExt {
+			if _, ok := w.nativeBackupTime(logfilename, strings.TrimSuffix(f, suffix)); !ok {
+				continue
+			}
+		}
+		// adopted backups may match native pattern
+		if _, ok := w.adoptedTime(f); !ok {
+			files = append(files, f)
+		}
+	}
 	return files, nil
 }
 
+// Sort backups by rotation time, oldest first.
+func (w *fileLogWriter) sortBackups(logfilename string, files []string) {
+	times := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		times[f] = w.backupTime(logfilename, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return w.olderBackup(logfilename, files[i], times[files[i]], files[j], times[files[j]])
+	})
+}
+
+// Reports whether backup a rotated before b, ta and tb are their rotation
+// time. Ties broken by number of numbered backups, larger is older, then by
+// name, so the order is deterministic.
+func (w *fileLogWriter) olderBackup(logfilename, a string, ta time.Time, b string, tb time.Time) bool {
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	na, _, okA := w.backupNumber(logfilename, a)
+	nb, _, okB := w.backupNumber(logfilename, b)
+	if okA && okB && na != nb {
+		return na > nb
+	}
+	return a < b
+}
+
 // Split the log filename to two parts: withoutExt, ext.
 func (w *fileLogWriter) splitLogFilename(logfilename string) (without, ext string) {
 	ext = filepath.Ext(logfilename)
@@ -166,26 +1224,131 @@ func (w *fileLogWriter) splitLogFilename(logfilename string) (without, ext strin
 }
 
 func (w *fileLogWriter) newBackupFilename(logfilename string) string {
-	ext := filepath.Ext(logfilename)
-	return fmt.Sprintf(`%s-%s%s`, logfilename[:len(logfilename)-len(ext)], hal.Now().Format(`2006-01-02-150405`), ext)
+	return w.backupFilenameAt(logfilename, hal.Now())
 }
 
-func (w *fileLogWriter) fileSize() (int64, error) {
-	info, err := w.f.Stat()
-	if err != nil {
-		return 0, err
+func (w *fileLogWriter) backupFilenameAt(logfilename string, t time.Time) string {
+	if w.backupName != nil {
+		base, ext := w.splitLogFilename(w.archivePath(logfilename))
+		return w.backupName(base, ext, t)
 	}
-	return info.Size(), nil
+	prefix, ext := w.backupNameParts(logfilename)
+	return prefix + w.backupOrigin() + t.In(w.backupLocation()).Format(w.timeLayout()) + ext
 }
 
-func openLogFile(path string) (f *os.File, err error) {
-	f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModeAppend|os.ModePerm)
+// Parse rotation time from backup file name, fallback to file modification
+// time.
+func (w *fileLogWriter) backupTime(logfilename, backup string) time.Time {
+	if t, ok := w.adoptedTime(backup); ok {
+		return t
+	}
+
+	name := w.trimArchiveSuffix(backup)
+	if t, ok := w.nativeBackupTime(logfilename, name); ok {
+		return t
+	}
+
+	if info, err := os.Stat(backup); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+func (w *fileLogWriter) stats() WriterStats {
+	return w.snapshot("file", w.CurrentPath())
+}
+
+func (w *fileLogWriter) workerStatus() string {
+	w.l.Lock()
+	closed := w.closed
+	w.l.Unlock()
+	return fmt.Sprintf("background compressions: %d, closed: %v", atomic.LoadInt64(&w.background), closed)
+}
+
+// Write or reopen log file failed, logs are lost.
+func (w *fileLogWriter) health() *Problem {
+	if p := w.failure.problem(&w.writerCounters, "file", w.CurrentPath(), Broken, 0); p != nil {
+		return p
+	}
+	// Disk full, records write to stderr.
+	if p := w.spaceFailure.problem(&w.writerCounters, "file", w.CurrentPath(), Degraded, 0); p != nil {
+		return p
+	}
+	return w.permDrift.problem(&w.writerCounters, "file", w.CurrentPath(), Degraded, 0)
+}
+
+// Open log file, perm is mode of created file, defaultFileMode if 0.
+func openLogFile(path string, perm os.FileMode) (f *os.File, err error) {
+	if perm == 0 {
+		perm = defaultFileMode
+	}
+	f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModeAppend|perm)
 	if err != nil && os.IsNotExist(err) {
 		if e := os.MkdirAll(filepath.Dir(path), 0700); e != nil {
 			log.Printf("[%s] Create log directory failed: %s", tag, e)
 			return
 		}
-		f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModeAppend|os.ModePerm)
+		f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModeAppend|perm)
 	}
 	return
 }
+
+// Stats returns statistics of the log file and its archives on disk, and
+// runtime counters. Lists log directory, but not blocks Write. Counters are
+// updated atomically, safe to call from any goroutine, kept across rotations
+// and start from zero for each created writer.
+//
+// To publish to expvar:
+//
+//	expvar.Publish("applog", expvar.Func(func() interface{} {
+//		s, _ := w.Stats()
+//		return s
+//	}))
+func (w *fileLogWriter) Stats() (FileStats, error) {
+	counters := w.stats()
+	r := FileStats{
+		ID:           w.id,
+		Path:         w.CurrentPath(),
+		Size:         counters.FileSize,
+		Written:      counters.Written,
+		Dropped:      counters.Dropped,
+		Rotations:    counters.Rotations,
+		LastRotation: counters.LastRotation,
+
+		FollowDropped: atomic.LoadInt64(&w.followDropped),
+		EventsDropped: atomic.LoadInt64(&w.eventsDropped),
+	}
+	if info, err := os.Stat(w.CurrentPath()); err == nil {
+		r.Size = info.Size()
+	}
+
+	archives, err := w.getCompressedFiles(w.CurrentPath())
+	if err != nil {
+		return r, err
+	}
+	for _, f := range archives {
+		info, err := os.Stat(f)
+		if err != nil {
+			// deleted by retention
+			continue
+		}
+		r.Archives++
+		r.ArchivesSize += info.Size()
+
+		t := w.backupTime(w.CurrentPath(), f)
+		if r.OldestArchive.IsZero() || t.Before(r.OldestArchive) {
+			r.OldestArchive = t
+		}
+		if r.NewestPath == "" || w.olderBackup(w.CurrentPath(), r.NewestPath, r.NewestArchive, f, t) {
+			r.NewestArchive, r.NewestPath = t, f
+		}
+	}
+
+	pending, err := w.getUncompressedFiles(w.CurrentPath())
+	if err != nil {
+		return r, err
+	}
+	r.PendingBackups = len(pending)
+	r.FailedCompressions = w.failedCompressions()
+	return r, nil
+}
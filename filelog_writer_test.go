@@ -0,0 +1,345 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// Returns a file log writer of app.log in a temp dir, closed on cleanup.
+func newTestFileWriter(t testing.TB, maxLen int64, maxFiles int, opts ...Option) *fileLogWriter {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "app.log")
+	return openTestFileWriter(t, path, maxLen, maxFiles, opts...)
+}
+
+// Returns a file log writer of path, closed on cleanup.
+func openTestFileWriter(t testing.TB, path string, maxLen int64, maxFiles int, opts ...Option) *fileLogWriter {
+	t.Helper()
+	wc, err := NewFileLogWriter(path, maxLen, maxFiles, append([]Option{WithoutRegistry()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = wc.Close() })
+	return wc.(*fileLogWriter)
+}
+
+// Write a line then rotate, n times, waits background jobs finished.
+func rotateTimes(t testing.TB, w *fileLogWriter, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.waitBackground()
+}
+
+var errTestCompress = errors.New("test compression failure")
+
+// Gzip compressor fails first fails calls, records time of each call.
+type flakyCompressor struct {
+	fails int
+
+	l     sync.Mutex
+	calls []time.Time
+}
+
+func (c *flakyCompressor) Compress(src, dst string) error {
+	c.l.Lock()
+	c.calls = append(c.calls, time.Now())
+	n := len(c.calls)
+	c.l.Unlock()
+	if n <= c.fails {
+		return errTestCompress
+	}
+	return codecBySuffix(c.Suffix()).Compress(src, dst)
+}
+
+func (c *flakyCompressor) Suffix() string { return `.gz` }
+
+func (c *flakyCompressor) attempts() []time.Time {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return append([]time.Time(nil), c.calls...)
+}
+
+// Returns decompressed content of gzip archive.
+func readArchive(t testing.TB, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// Wait until cond true, fails after 10 seconds.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Returns content of archives and the live log file of path, oldest first.
+func readAllLogs(t testing.TB, path string) string {
+	t.Helper()
+	r, err := OpenLogs(path, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// Returns content of archives of w, oldest first, waits background jobs
+// finished.
+func archiveContents(t testing.TB, w *fileLogWriter) []string {
+	t.Helper()
+	w.waitBackground()
+	archives, err := w.ListArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r []string
+	for i := len(archives) - 1; i >= 0; i-- {
+		r = append(r, readArchive(t, archives[i].Path))
+	}
+	return r
+}
+
+// Live log file never exceeds maxLen, rotated before a write would exceed
+// it, a record larger than maxLen written whole to its own file.
+func TestRotateBeforeExceedMaxLen(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		archives []string
+		live     string
+	}{
+		{"under", []string{"12345", "1234"}, nil, "123451234"},
+		{"exactly equal", []string{"12345", "67890"}, []string{"1234567890"}, ""},
+		{"one byte over", []string{"12345", "678901"}, []string{"12345"}, "678901"},
+		{"larger than maxLen", []string{"123", "123456789012345", "12"},
+			[]string{"123", "123456789012345"}, "12"},
+		{"larger than maxLen at start", []string{"123456789012345"}, []string{"123456789012345"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestFileWriter(t, 10, 0)
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+					t.Fatalf("write %q: %d, %v", s, n, err)
+				}
+			}
+			if got := archiveContents(t, w); !reflect.DeepEqual(got, tt.archives) {
+				t.Errorf("archives %q, want %q", got, tt.archives)
+			}
+			live, err := ioutil.ReadFile(w.CurrentPath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(live) != tt.live {
+				t.Errorf("live log file %q, want %q", live, tt.live)
+			}
+		})
+	}
+}
+
+// Rotated after interval passed, though few bytes written, once however
+// many intervals passed.
+func TestRotateEvery(t *testing.T) {
+	tests := []struct {
+		name     string
+		after    time.Duration
+		archives []string
+	}{
+		{"not reached", 59 * time.Minute, nil},
+		{"reached", time.Hour, []string{"a\nb\n"}},
+		{"many intervals", 25 * time.Hour, []string{"a\nb\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+			freezeNow(t, now)
+			w := newTestFileWriter(t, 1<<20, 0, WithRotateEvery(time.Hour))
+			if _, err := w.Write([]byte("a\n")); err != nil {
+				t.Fatal(err)
+			}
+			freezeNow(t, now.Add(tt.after))
+			if _, err := w.Write([]byte("b\n")); err != nil {
+				t.Fatal(err)
+			}
+			if got := archiveContents(t, w); !reflect.DeepEqual(got, tt.archives) {
+				t.Errorf("archives %q, want %q", got, tt.archives)
+			}
+		})
+	}
+}
+
+// Concurrent writes with size triggered and explicit rotations, records
+// neither lost nor interleaved, run with -race.
+func TestConcurrentWriteRotate(t *testing.T) {
+	const writers, records = 8, 200
+	w := newTestFileWriter(t, 4096, 0)
+	path := w.CurrentPath()
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < records; j++ {
+				if _, err := fmt.Fprintf(w, "writer %d record %d\n", i, j); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	stop := make(chan struct{})
+	rotated := make(chan struct{})
+	go func() {
+		defer close(rotated)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := w.Rotate(); err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-rotated
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSuffix(readAllLogs(t, path), "\n"), "\n") {
+		var i, j int
+		if _, err := fmt.Sscanf(line, "writer %d record %d", &i, &j); err != nil {
+			t.Fatalf("corrupt line %q", line)
+		}
+		seen[line] = true
+	}
+	if len(seen) != writers*records {
+		t.Errorf("%d records, want %d", len(seen), writers*records)
+	}
+}
+
+var benchRecord = []byte("2024-05-01 15:04:05 [INFO] handled request, status 200, 1.2ms\n")
+
+func BenchmarkFileLogWriterWrite(b *testing.B) {
+	w := newTestFileWriter(b, 0, 0)
+	b.SetBytes(int64(len(benchRecord)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := w.Write(benchRecord); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Stat the live log file on every write, such as before tracking its size,
+// or periodically.
+func BenchmarkFileLogWriterStat(b *testing.B) {
+	for _, n := range []int{1, statInterval} {
+		b.Run(fmt.Sprintf("every %d writes", n), func(b *testing.B) {
+			old := statInterval
+			statInterval = n
+			defer func() { statInterval = old }()
+
+			w := newTestFileWriter(b, 0, 0)
+			b.SetBytes(int64(len(benchRecord)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := w.Write(benchRecord); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Write records one write(2) each, or coalesced by buffer.
+func BenchmarkFileLogWriterBuffer(b *testing.B) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"unbuffered", nil},
+		{"buffer 4KB", []Option{WithBuffer(4*1024, 0)}},
+		{"buffer 64KB", []Option{WithBuffer(0, 0)}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			w := newTestFileWriter(b, 0, 0, tt.opts...)
+			b.SetBytes(int64(len(benchRecord)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := w.Write(benchRecord); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFileLogWriterWriteParallel(b *testing.B) {
+	w := newTestFileWriter(b, 0, 0)
+	b.SetBytes(int64(len(benchRecord)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := w.Write(benchRecord); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// Replace hal.Now by a clock frozen at t, restored on cleanup.
+func freezeNow(t testing.TB, now time.Time) {
+	t.Helper()
+	hal.Now = func() time.Time { return now }
+	t.Cleanup(func() { hal.Now = time.Now })
+}
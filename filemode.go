@@ -0,0 +1,25 @@
+package logging
+
+import (
+	"os"
+	"strconv"
+)
+
+// FileMode is os.FileMode can be encoded in config file as octal string, such
+// as "0640".
+type FileMode os.FileMode
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (m *FileMode) UnmarshalText(text []byte) error {
+	v, err := strconv.ParseUint(string(text), 8, 32)
+	if err != nil {
+		return err
+	}
+	*m = FileMode(os.FileMode(v).Perm())
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m FileMode) MarshalText() ([]byte, error) {
+	return []byte("0" + strconv.FormatUint(uint64(m), 8)), nil
+}
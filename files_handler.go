@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FilesHandler returns http.Handler serves log files of the log file created
+// by config, or the first alive file log writer:
+//
+//	GET /: json list of the live log file and archives
+//	GET /<name>: download the file, supports Range requests. Archive
+//	  decompressed if query param "decompress" is "1" or "true", Range not
+//	  supported in that case.
+//
+// Only files listed are served.
+func FilesHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w, _ := defaultWriters()
+		if w == nil {
+			http.Error(rw, ErrNoFileLogWriter.Error(), http.StatusNotFound)
+			return
+		}
+
+		files, err := w.listFiles()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "" {
+			rw.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(rw).Encode(files)
+			return
+		}
+
+		for _, f := range files {
+			if f.Name == name {
+				w.serveLogFile(rw, r, f)
+				return
+			}
+		}
+		http.NotFound(rw, r)
+	})
+}
+
+// Log file listed by FilesHandler.
+type listedFile struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	RotatedAt  time.Time `json:"rotated_at,omitempty"`
+	Compressed bool      `json:"compressed"`
+	Live       bool      `json:"live"`
+
+	path string
+}
+
+// Returns the live log file and archives.
+func (w *fileLogWriter) listFiles() ([]listedFile, error) {
+	archives, err := w.listArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	r := make([]listedFile, 0, len(archives)+1)
+	if info, err := os.Stat(w.CurrentPath()); err == nil {
+		r = append(r, listedFile{Name: filepath.Base(w.CurrentPath()), Size: info.Size(), Live: true, path: w.CurrentPath()})
+	}
+	for _, a := range archives {
+		r = append(r, listedFile{
+			Name:       filepath.Base(a.Path),
+			Size:       a.Size,
+			RotatedAt:  a.RotatedAt,
+			Compressed: a.Compressed,
+			path:       a.Path,
+		})
+	}
+	return r, nil
+}
+
+func (w *fileLogWriter) serveLogFile(rw http.ResponseWriter, r *http.Request, lf listedFile) {
+	decompress := r.URL.Query().Get("decompress")
+	if lf.Compressed && (decompress == "1" || decompress == "true") {
+		src, err := OpenArchiveKey(lf.path, w.key)
+		if err != nil {
+			serveFileError(rw, err)
+			return
+		}
+		defer safeClose("files", src)
+
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		name := w.trimArchiveSuffix(lf.Name)
+		rw.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		if r.Method != http.MethodHead {
+			_, _ = io.Copy(rw, src)
+		}
+		return
+	}
+
+	f, err := os.Open(lf.path)
+	if err != nil {
+		serveFileError(rw, err)
+		return
+	}
+	defer safeClose("files", f)
+
+	info, err := f.Stat()
+	if err != nil {
+		serveFileError(rw, err)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(lf.path, encSuffix):
+		rw.Header().Set("Content-Type", "application/octet-stream")
+	case lf.Compressed && codecBySuffix(w.archiveSuffix(lf.path)) != nil:
+		rw.Header().Set("Content-Type", codecBySuffix(w.archiveSuffix(lf.path)).contentType)
+	case lf.Compressed:
+		rw.Header().Set("Content-Type", "application/octet-stream")
+	default:
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	rw.Header().Set("Content-Disposition", `attachment; filename="`+lf.Name+`"`)
+	http.ServeContent(rw, r, lf.Name, info.ModTime(), f)
+}
+
+func serveFileError(rw http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		// deleted by retention
+		http.Error(rw, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(rw, err.Error(), http.StatusInternalServerError)
+}
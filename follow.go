@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// interval to check new content and rotation of followed log file
+	followPollInterval = 200 * time.Millisecond
+
+	followBuffer = 256
+)
+
+// Follow streams lines written to the log file, like `tail -F`, switches to
+// the new log file after rotation. Starts from end of the live log file if
+// fromEnd is true, otherwise from the beginning.
+//
+// Lines are dropped if the consumer is slow, counted by
+// FileStats.FollowDropped. Channel closed and file released after ctx done.
+// Rotation checked by polling, a log file rotated away within the poll
+// interval (200ms) may be skipped.
+func (w *fileLogWriter) Follow(ctx context.Context, fromEnd bool) (<-chan []byte, error) {
+	f, err := os.Open(w.CurrentPath())
+	if err != nil {
+		return nil, err
+	}
+	if fromEnd {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			safeClose("follow", f)
+			return nil, err
+		}
+	}
+
+	ch := make(chan []byte, followBuffer)
+	go w.follow(ctx, f, ch)
+	return ch, nil
+}
+
+func (w *fileLogWriter) follow(ctx context.Context, f *os.File, ch chan<- []byte) {
+	defer close(ch)
+	var next *os.File // the new log file after rotation, drain f before switch
+	defer func() {
+		safeClose("follow", f)
+		if next != nil {
+			safeClose("follow", next)
+		}
+	}()
+
+	br := bufio.NewReader(f)
+	var partial []byte
+	send := func(line []byte) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case ch <- line:
+		case <-ctx.Done():
+			return false
+		default:
+			atomic.AddInt64(&w.followDropped, 1)
+		}
+		return true
+	}
+
+	ticker := time.NewTicker(followPollInterval)
+	defer ticker.Stop()
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			partial = append(partial, line...)
+		}
+		if err == nil {
+			if !send(partial) {
+				return
+			}
+			partial = nil
+			continue
+		}
+		if err != io.EOF {
+			w.reportError("follow", err)
+			return
+		}
+
+		if next != nil {
+			// old file drained, no more content after rotation.
+			if len(partial) > 0 && !send(partial) {
+				return
+			}
+			partial = nil
+			safeClose("follow", f)
+			f, next = next, nil
+			br.Reset(f)
+			continue
+		}
+
+		// reached end of current file, drain it once more if rotated, writes
+		// may happen between the read and the rename.
+		if next = w.followReopen(f); next != nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Open the new log file if rotated, f is the current followed file. Returns
+// nil if not rotated.
+func (w *fileLogWriter) followReopen(f *os.File) *os.File {
+	cur, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(w.CurrentPath())
+	if err != nil || os.SameFile(cur, info) {
+		return nil
+	}
+
+	nf, err := os.Open(w.CurrentPath())
+	if err != nil {
+		return nil
+	}
+	return nf
+}
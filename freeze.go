@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// max bytes of records buffered in memory while frozen, more are dropped
+const freezeBufferMax = 4 * 1024 * 1024
+
+var errAlreadyFrozen = errors.New("logging: writer already frozen")
+
+// Freeze fsyncs the live log file, and pauses writing, rotation, compression
+// and retention until Thaw(), such as for filesystem snapshot. Records
+// written while frozen buffered in memory, at most 4MB, more are dropped.
+// Auto thawed with an internal error if ctx done before Thaw().
+func (w *fileLogWriter) Freeze(ctx context.Context) error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	switch {
+	case w.closed:
+		return ErrWriterClosed
+	case w.frozen != nil:
+		return errAlreadyFrozen
+	case w.f == nil && !w.lazyOpen:
+		return errLogFileNotOpen
+	}
+	if w.f != nil {
+		if err := w.syncLocked(); err != nil {
+			return err
+		}
+	}
+
+	frozen := make(chan struct{})
+	w.frozen = frozen
+	go func() {
+		select {
+		case <-ctx.Done():
+			if w.thaw(frozen) {
+				w.reportError("freeze", fmt.Errorf("auto thawed: %w", ctx.Err()))
+			}
+		case <-frozen:
+		}
+	}()
+	return nil
+}
+
+// Thaw resumes writing paused by Freeze(), buffered records written.
+func (w *fileLogWriter) Thaw() {
+	w.l.Lock()
+	frozen := w.frozen
+	w.l.Unlock()
+
+	if frozen != nil {
+		w.thaw(frozen)
+	}
+}
+
+// Thaw if still frozen by the same Freeze() call, returns false if already
+// thawed.
+func (w *fileLogWriter) thaw(frozen chan struct{}) bool {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	return w.thawLocked(frozen)
+}
+
+// Same as thaw(), must hold w.l.
+func (w *fileLogWriter) thawLocked(frozen chan struct{}) bool {
+	if w.frozen != frozen {
+		return false
+	}
+	w.frozen = nil
+	close(frozen)
+
+	records := w.frozenBuf
+	w.frozenBuf, w.frozenBytes = nil, 0
+	for _, p := range records {
+		if _, err := w.writeLocked(p, false); err != nil {
+			w.reportError("freeze", err)
+		}
+	}
+	return true
+}
+
+// Buffer record written while frozen. Must hold w.l.
+func (w *fileLogWriter) freezeRecord(p []byte) {
+	if w.frozenBytes+len(p) > freezeBufferMax {
+		w.drop(1)
+		return
+	}
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	w.frozenBuf = append(w.frozenBuf, buf)
+	w.frozenBytes += len(p)
+}
+
+// Blocks background tasks while frozen.
+func (w *fileLogWriter) waitThawed() {
+	w.l.Lock()
+	frozen := w.frozen
+	w.l.Unlock()
+
+	if frozen != nil {
+		<-frozen
+	}
+}
+
+// Freeze freezes the log file created by config, or the first alive file log
+// writer, see Freeze method of file log writer.
+func Freeze(ctx context.Context) error {
+	w, _ := defaultWriters()
+	if w == nil {
+		return ErrNoFileLogWriter
+	}
+	return w.Freeze(ctx)
+}
+
+// Thaw thaws the log file frozen by Freeze().
+func Thaw() error {
+	w, _ := defaultWriters()
+	if w == nil {
+		return ErrNoFileLogWriter
+	}
+	w.Thaw()
+	return nil
+}
@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"time"
+)
+
+// WithSyncEvery makes file log writer fsync the live log file after every n
+// bytes written. Zero, the default, never fsync except WithSyncOnError().
+func WithSyncEvery(n int64) Option {
+	return func(o *writerOptions) {
+		o.syncEvery = n
+	}
+}
+
+// WithSyncInterval makes file log writer fsync the live log file every d, if
+// written since last fsync. Fsync runs in a background goroutine, stopped on
+// Close. Zero, the default, never fsync except WithSyncOnError().
+func WithSyncInterval(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.syncInterval = d
+	}
+}
+
+// Count n bytes written, fsync if reached syncEvery. Must hold w.l.
+func (w *fileLogWriter) countUnsynced(n int) error {
+	if w.syncEvery <= 0 && w.syncStop == nil {
+		return nil
+	}
+
+	w.unsynced += int64(n)
+	if w.syncEvery > 0 && w.unsynced >= w.syncEvery {
+		return w.syncLocked()
+	}
+	return nil
+}
+
+// Fsync the live log file, must hold w.l.
+func (w *fileLogWriter) syncLocked() error {
+	if err := w.flushLocked(); err != nil {
+		return err
+	}
+	w.unsynced = 0
+	return w.f.Sync()
+}
+
+// Fsync the live log file every d until stop closed.
+func (w *fileLogWriter) runSyncTicker(d time.Duration, stop chan struct{}) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			w.syncPending()
+		}
+	}
+}
+
+// Fsync the live log file if written since last fsync. Holds w.l, so never
+// syncs a file closed by rotation.
+func (w *fileLogWriter) syncPending() {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed || w.f == nil || w.unsynced == 0 {
+		return
+	}
+	if debugEnabled() {
+		debugf("sync: %s, %d bytes since last sync", w.CurrentPath(), w.unsynced)
+	}
+	if err := w.syncLocked(); err != nil {
+		w.reportError("sync", err)
+	}
+}
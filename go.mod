@@ -3,10 +3,15 @@ module github.com/redforks/logging
 go 1.15
 
 require (
+	github.com/klauspost/compress v1.13.6
+	github.com/klauspost/pgzip v1.2.5
+	github.com/prometheus/client_golang v1.11.1
 	github.com/redforks/appinfo v1.0.0
 	github.com/redforks/config v1.0.0
 	github.com/redforks/hal v1.0.0
 	github.com/redforks/life v1.0.0
 	github.com/redforks/testing v1.0.0
 	github.com/redforks/xdgdirs v1.0.1
+	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/metric v0.20.0
 )
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithLazyOpen makes file log writer open the live log file on first write,
+// instead of on creation. The file handle is managed by a package level cache,
+// least recently used handles closed if open handles exceed the limit, see
+// SetMaxOpenFiles(), and reopened on demand.
+func WithLazyOpen() Option {
+	return func(o *writerOptions) {
+		o.lazyOpen = true
+	}
+}
+
+const defaultMaxOpenFiles = 128
+
+// Open file handles of lazy open file log writers, most recently used at
+// front.
+var handleCache = struct {
+	l     sync.Mutex
+	max   int
+	lru   *list.List
+	items map[*fileLogWriter]*list.Element
+}{
+	max:   defaultMaxOpenFiles,
+	lru:   list.New(),
+	items: map[*fileLogWriter]*list.Element{},
+}
+
+// SetMaxOpenFiles set max open file handles of lazy open file log writers,
+// see WithLazyOpen(), default to 128. Excess handles closed on next write.
+func SetMaxOpenFiles(n int) {
+	if n < 1 {
+		n = 1
+	}
+	handleCache.l.Lock()
+	handleCache.max = n
+	handleCache.l.Unlock()
+}
+
+// OpenHandles returns open file handles of lazy open file log writers.
+func OpenHandles() int {
+	handleCache.l.Lock()
+	defer handleCache.l.Unlock()
+	return handleCache.lru.Len()
+}
+
+// Record w used its handle, move w to front.
+func handleUsed(w *fileLogWriter) {
+	handleCache.l.Lock()
+	defer handleCache.l.Unlock()
+
+	if e, ok := handleCache.items[w]; ok {
+		handleCache.lru.MoveToFront(e)
+		return
+	}
+	handleCache.items[w] = handleCache.lru.PushFront(w)
+}
+
+// Forget w, its handle closed.
+func handleClosed(w *fileLogWriter) {
+	handleCache.l.Lock()
+	defer handleCache.l.Unlock()
+
+	if e, ok := handleCache.items[w]; ok {
+		handleCache.lru.Remove(e)
+		delete(handleCache.items, w)
+	}
+}
+
+// Close least recently used handles exceed the limit, except self. Must not
+// hold lock of any writer.
+func evictHandles(self *fileLogWriter) {
+	for {
+		handleCache.l.Lock()
+		var victim *fileLogWriter
+		if handleCache.lru.Len() > handleCache.max {
+			for e := handleCache.lru.Back(); e != nil; e = e.Prev() {
+				if w := e.Value.(*fileLogWriter); w != self {
+					victim = w
+					break
+				}
+			}
+		}
+		handleCache.l.Unlock()
+
+		if victim == nil {
+			return
+		}
+		victim.closeHandle()
+	}
+}
+
+// Close the handle of lazy open writer, reopened on next write.
+func (w *fileLogWriter) closeHandle() {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.f != nil {
+		if debugEnabled() {
+			debugf("handle: close idle %s", w.CurrentPath())
+		}
+		if err := w.flushLocked(); err != nil {
+			w.reportError("file", err)
+		}
+		safeClose("file", w.f)
+		w.f = nil
+	}
+	handleClosed(w)
+}
+
+// Open the live log file if lazy open and handle closed. Must hold w.l.
+func (w *fileLogWriter) ensureOpen() error {
+	if !w.lazyOpen {
+		if w.f == nil {
+			return errLogFileNotOpen
+		}
+		return nil
+	}
+
+	if w.f == nil {
+		f, err := w.openFile(w.CurrentPath())
+		if err != nil {
+			return err
+		}
+		w.f = f
+		w.writeHeader()
+	}
+	handleUsed(w)
+	return nil
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/redforks/appinfo"
+	"github.com/redforks/hal"
+)
+
+// HeaderFunc returns header written at the top of each new log file, see
+// WithHeader().
+type HeaderFunc func() []byte
+
+// WithHeader writes header returned by h at the top of each new log file:
+// initial open of an empty file and after each rotation, not written if
+// appending to a non-empty file. Header counts toward log file size. Use
+// DefaultHeader for a line of app name, version, pid, hostname and time.
+func WithHeader(h HeaderFunc) Option {
+	return func(o *writerOptions) {
+		o.header = h
+	}
+}
+
+// DefaultHeader is a HeaderFunc returns a line of app name, version, pid,
+// hostname and time, formatted as a log line of this package, such as:
+//
+//	2024/05/01 15:04:05 [logging] log file of myapp 1.2.0, pid 1234, host web-1
+func DefaultHeader() []byte {
+	host, _ := os.Hostname()
+	return []byte(fmt.Sprintf("%s [%s] log file of %s %s, pid %d, host %s\n",
+		hal.Now().Format(`2006/01/02 15:04:05`), tag, appinfo.CodeName(), appinfo.Version(), os.Getpid(), host))
+}
+
+// Write header if the live log file is empty. Must hold w.l.
+func (w *fileLogWriter) writeHeader() {
+	if w.header == nil || w.f == nil || atomic.LoadInt64(&w.currentSize) != 0 {
+		return
+	}
+
+	w.headerLen = 0
+	h := w.header()
+	if len(h) == 0 {
+		return
+	}
+	n, err := w.writeFull(h)
+	w.headerLen = int64(n)
+	atomic.AddInt64(&w.currentSize, int64(n))
+	atomic.AddInt64(&w.written, int64(n))
+	if err != nil {
+		w.reportError("file", fmt.Errorf("write header: %w", err))
+	}
+}
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// Status is the health status of logging.
+type Status int
+
+const (
+	// Healthy means all writers working.
+	Healthy Status = iota
+
+	// Degraded means logs still recorded, but some component failing, such
+	// as network sink disconnected, logs spooled or go to fallback writer.
+	Degraded
+
+	// Broken means logs are lost.
+	Broken
+)
+
+func (s Status) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Broken:
+		return "broken"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SinkDownThreshold is how long a network sink (primary writer of
+// FailoverWriter, inner writer of SpoolWriter) keeps failing before reported
+// as a health problem.
+var SinkDownThreshold = time.Minute
+
+// Problem describes a failing component.
+type Problem struct {
+	Writer    string    // writer ID
+	Component string    // writer type: "file", "async", "failover", "spool"
+	Path      string    // log file path or spool directory, empty if not apply
+	Status    Status    // Degraded or Broken
+	Err       error     // the underlying error
+	Since     time.Time // when the component starts failing
+}
+
+// Health returns health status of all writers created by this package,
+// status is the worst status of problems.
+func Health() (Status, []Problem) {
+	status := Healthy
+	var problems []Problem
+	for _, w := range registeredWriters() {
+		hc, ok := w.(healthChecker)
+		if !ok {
+			continue
+		}
+
+		if p := hc.health(); p != nil {
+			problems = append(problems, *p)
+			if p.Status > status {
+				status = p.Status
+			}
+		}
+	}
+	return status, problems
+}
+
+// HealthHandler is a http.HandlerFunc, responses health status as json,
+// status code is 200 if not Broken, otherwise 503.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	type problem struct {
+		Writer    string    `json:"writer"`
+		Component string    `json:"component"`
+		Path      string    `json:"path,omitempty"`
+		Status    Status    `json:"status"`
+		Error     string    `json:"error"`
+		Since     time.Time `json:"since"`
+	}
+	status, problems := Health()
+	body := struct {
+		Status   Status    `json:"status"`
+		Problems []problem `json:"problems"`
+	}{Status: status, Problems: []problem{}}
+	for _, p := range problems {
+		var msg string
+		if p.Err != nil {
+			msg = p.Err.Error()
+		}
+		body.Problems = append(body.Problems, problem{p.Writer, p.Component, p.Path, p.Status, msg, p.Since})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status == Broken {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Writers implement healthChecker, returns nil if healthy.
+type healthChecker interface {
+	health() *Problem
+}
+
+// Records error of a component and since when, cheap to clear on success.
+type failureState struct {
+	failing int32 // 1 if failing, access by atomic
+
+	l     sync.Mutex
+	err   error
+	since time.Time
+}
+
+// Record a failure, since not updated if already failing.
+func (s *failureState) set(err error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.err = err
+	if atomic.LoadInt32(&s.failing) == 0 {
+		s.since = hal.Now()
+		atomic.StoreInt32(&s.failing, 1)
+	}
+}
+
+func (s *failureState) clear() {
+	if atomic.LoadInt32(&s.failing) == 0 {
+		return
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.err, s.since = nil, time.Time{}
+	atomic.StoreInt32(&s.failing, 0)
+}
+
+// Returns nil error if not failing.
+func (s *failureState) get() (since time.Time, err error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.since, s.err
+}
+
+// Create Problem if failing, or failing longer than threshold.
+func (s *failureState) problem(c *writerCounters, component, path string, status Status, threshold time.Duration) *Problem {
+	since, err := s.get()
+	if err == nil || hal.Now().Sub(since) < threshold {
+		return nil
+	}
+	return &Problem{c.id, component, path, status, err, since}
+}
@@ -0,0 +1,189 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// InternalErrorHandler handles internal errors of this package, such as
+// failed to compress or delete archives. Component is the part reports the
+// error: "file", "async", "compress", "retention", "failover", "spool".
+//
+// Handler may called concurrently. If a handler reports internal error again
+// (such as logs the error to a failing log writer), the nested error goes to
+// the default handler, handler never called re-entrantly.
+type InternalErrorHandler func(component string, err error)
+
+// holds InternalErrorHandler
+var internalErrorHandler atomic.Value
+
+// SetInternalErrorHandler replace internal error handler, nil to restore the
+// default handler, which writes errors to stderr, one error per line, and at
+// most a few lines per minute.
+func SetInternalErrorHandler(h InternalErrorHandler) {
+	if h == nil {
+		h = defaultInternalErrorHandler
+	}
+	internalErrorHandler.Store(h)
+}
+
+var (
+	// goroutines currently running internal error handler
+	reportingLock sync.Mutex
+	reporting     = map[uint64]bool{}
+)
+
+// Report internal error to installed handler.
+func reportError(component string, err error) {
+	h, _ := internalErrorHandler.Load().(InternalErrorHandler)
+	if h == nil {
+		h = defaultInternalErrorHandler
+	}
+
+	recordRecentError(component, err)
+
+	id := goroutineID()
+	reportingLock.Lock()
+	nested := reporting[id]
+	if !nested {
+		reporting[id] = true
+	}
+	reportingLock.Unlock()
+
+	if nested {
+		defaultInternalErrorHandler(component, err)
+		return
+	}
+
+	defer func() {
+		reportingLock.Lock()
+		delete(reporting, id)
+		reportingLock.Unlock()
+	}()
+	h(component, err)
+}
+
+// max recent internal errors kept for DumpState
+const maxRecentErrors = 20
+
+type recentError struct {
+	Time      time.Time
+	Component string
+	Err       string
+}
+
+var (
+	recentErrorsLock sync.Mutex
+	recentErrors     []recentError
+)
+
+func recordRecentError(component string, err error) {
+	recentErrorsLock.Lock()
+	defer recentErrorsLock.Unlock()
+
+	if len(recentErrors) == maxRecentErrors {
+		recentErrors = append(recentErrors[:0], recentErrors[1:]...)
+	}
+	recentErrors = append(recentErrors, recentError{hal.Now(), component, err.Error()})
+}
+
+func getRecentErrors() []recentError {
+	recentErrorsLock.Lock()
+	defer recentErrorsLock.Unlock()
+
+	r := make([]recentError, len(recentErrors))
+	copy(r, recentErrors)
+	return r
+}
+
+// Parse current goroutine id from stack trace, slow, not for Write path.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+const (
+	// max lines per minute per component written by default internal error
+	// handler
+	internalErrorRate = 5
+)
+
+// Throttle state of a component for default internal error handler.
+type errorThrottle struct {
+	windowStart    time.Time
+	lines          int    // lines written in current window
+	last           string // last written error message
+	suppressed     int    // errors suppressed since last written line
+	lastSuppressed string // last suppressed error message
+}
+
+var (
+	defaultHandlerLock sync.Mutex
+	errorThrottles     = map[string]*errorThrottle{}
+)
+
+// Write error to stderr. Identical consecutive errors are suppressed in a one
+// minute window, and at most internalErrorRate lines per minute per component,
+// suppressed count reported when error changed or the window rolls over.
+func defaultInternalErrorHandler(component string, err error) {
+	defaultHandlerLock.Lock()
+	defer defaultHandlerLock.Unlock()
+
+	now := hal.Now()
+	msg := err.Error()
+	t := errorThrottles[component]
+	if t == nil {
+		t = &errorThrottle{windowStart: now}
+		errorThrottles[component] = t
+	}
+
+	if now.Sub(t.windowStart) >= time.Minute {
+		t.flushSuppressed(now, component)
+		t.windowStart, t.lines, t.last = now, 0, ""
+	}
+
+	if msg == t.last || t.lines >= internalErrorRate {
+		t.suppressed++
+		t.lastSuppressed = msg
+		return
+	}
+
+	t.flushSuppressed(now, component)
+	t.last = msg
+	t.lines++
+	writeInternalError(now, component, msg)
+}
+
+func (t *errorThrottle) flushSuppressed(now time.Time, component string) {
+	if t.suppressed > 0 {
+		writeInternalError(now, component, fmt.Sprintf("%d errors suppressed, last: %s", t.suppressed, t.lastSuppressed))
+		t.suppressed, t.lastSuppressed = 0, ""
+	}
+}
+
+func writeInternalError(now time.Time, component, msg string) {
+	msg = strings.TrimRight(msg, "\n")
+	_, _ = fmt.Fprintf(os.Stderr, "%s [%s] %s: %s\n", now.Format(`2006/01/02 15:04:05`), tag, component, msg)
+}
+
+func safeClose(component string, f io.Closer) {
+	if err := f.Close(); err != nil {
+		reportError(component, err)
+	}
+}
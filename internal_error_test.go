@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Summary of suppressed errors reports the last suppressed one, not the last
+// written.
+func TestInternalErrorSuppressed(t *testing.T) {
+	tests := []struct {
+		name   string
+		errors []string
+		want   []string
+	}{
+		{"identical", []string{"a", "a", "a", "b"},
+			[]string{"a", "2 errors suppressed, last: a", "b"}},
+		{"rate limited", []string{"e1", "e2", "e3", "e4", "e5", "e6", "e7"},
+			[]string{"e1", "e2", "e3", "e4", "e5", "2 errors suppressed, last: e7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const component = "test"
+			defaultHandlerLock.Lock()
+			delete(errorThrottles, component)
+			defaultHandlerLock.Unlock()
+			stderr := captureStderr(t)
+			now := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+			freezeNow(t, now)
+			for _, msg := range tt.errors {
+				defaultInternalErrorHandler(component, errors.New(msg))
+			}
+			// window rolled over, suppressed reported
+			freezeNow(t, now.Add(time.Minute))
+			defaultInternalErrorHandler(component, errors.New("next window"))
+
+			var msgs []string
+			for _, line := range strings.Split(strings.TrimSuffix(stderr(), "\n"), "\n") {
+				// errors of other components, such as background of other tests
+				if i := strings.Index(line, "] "+component+": "); i >= 0 {
+					msgs = append(msgs, line[i+len(component)+4:])
+				}
+			}
+			if want := append(tt.want, "next window"); fmt.Sprint(msgs) != fmt.Sprint(want) {
+				t.Errorf("errors %q, want %q", msgs, want)
+			}
+		})
+	}
+}
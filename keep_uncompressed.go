@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"os"
+)
+
+// WithKeepUncompressed keeps the n most recent backups uncompressed, such as
+// for grep yesterday's log without zcat, older ones compressed in background
+// when fall out of the n most recent on rotation. Backups of both forms
+// counted by retention. Rotation events and WithOnRotate() callback of a
+// backup sent when it is compressed. Zero, the default, compresses backups on
+// rotation.
+func WithKeepUncompressed(n int) Option {
+	return func(o *writerOptions) {
+		o.keepUncompressed = n
+	}
+}
+
+// Compress uncompressed backups of logfilename, except the keepUncompressed
+// most recent ones. Called in background worker.
+func (w *fileLogWriter) compressOutsideWindow(logfilename string) error {
+	files, err := w.getUncompressedFiles(logfilename)
+	if err != nil {
+		return err
+	}
+	if len(files) <= w.keepUncompressed {
+		return nil
+	}
+
+	// oldest first
+	for _, f := range files[:len(files)-w.keepUncompressed] {
+		info, err := os.Stat(f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// deleted by retention
+				continue
+			}
+			return err
+		}
+		if err = w.archive(f, w.backupTime(logfilename, f), info.Size()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
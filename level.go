@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+)
+
+// Level is severity of a log record, parsed from the record text.
+type Level int
+
+// Levels, LevelUnknown if no level found in the record.
+const (
+	LevelUnknown Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	case LevelFatal:
+		return "fatal"
+	default:
+		return "unknown"
+	}
+}
+
+// max bytes of record prefix searched for level
+const levelScanLen = 64
+
+var levelWords = map[string]Level{
+	"FATAL":   LevelFatal,
+	"PANIC":   LevelFatal,
+	"CRIT":    LevelFatal,
+	"ERROR":   LevelError,
+	"ERRO":    LevelError,
+	"ERR":     LevelError,
+	"WARNING": LevelWarn,
+	"WARN":    LevelWarn,
+	"INFO":    LevelInfo,
+	"DEBUG":   LevelDebug,
+	"TRACE":   LevelDebug,
+}
+
+// ParseLevel finds level of a log record by the first level word,
+// case-insensitive, near the beginning of the record, such as "[ERROR]",
+// "level=error", "WARN:".
+func ParseLevel(record []byte) Level {
+	if len(record) > levelScanLen {
+		record = record[:levelScanLen]
+	}
+	for _, word := range bytes.FieldsFunc(record, func(r rune) bool { return !isWordRune(r) }) {
+		if l, ok := levelWords[string(bytes.ToUpper(word))]; ok {
+			return l
+		}
+	}
+	return LevelUnknown
+}
+
+func isWordRune(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// WithSyncOnError makes file log writer fsync after writing records at
+// LevelError or above, see ParseLevel(). Async writer with the option waits
+// such records synced by its inner writer, even if its queue is full.
+func WithSyncOnError() Option {
+	return func(o *writerOptions) {
+		o.syncOnError = true
+	}
+}
+
+// Writers implement synced writes, such as file log writer.
+type syncWriter interface {
+	writeSynced(p []byte) error
+}
+
+// Write p to w, and fsync if w supports.
+func writeAndSync(w io.Writer, p []byte) error {
+	if sw, ok := w.(syncWriter); ok {
+		return sw.writeSynced(p)
+	}
+	if _, err := w.Write(p); err != nil {
+		return err
+	}
+	if s, ok := w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LockPolicy decides what NewFileLogWriter does if the log file locked by
+// another process, see WithFileLock().
+type LockPolicy int
+
+const (
+	// LockFail makes NewFileLogWriter return ErrLogFileLocked.
+	LockFail LockPolicy = iota + 1
+
+	// LockFallbackPID makes NewFileLogWriter write to a log file named with
+	// pid instead, such as `app.1234.log`.
+	LockFallbackPID
+)
+
+// ErrLogFileLocked returned by NewFileLogWriter if the log file locked by
+// another process, see WithFileLock().
+var ErrLogFileLocked = errors.New("logging: log file locked by another process")
+
+const lockSuffix = `.lock`
+
+// WithFileLock makes NewFileLogWriter take an advisory lock on a sidecar file
+// of the log file, such as `app.log.lock`, released on Close, so two
+// processes never write and rotate the same log file. Policy decides what to
+// do if locked by another process. No-op on platforms without flock, such as
+// windows.
+func WithFileLock(policy LockPolicy) Option {
+	return func(o *writerOptions) {
+		o.lockPolicy = policy
+	}
+}
+
+// Lock log file of path, returns the log file path to use, may differ from
+// path by policy.
+func lockLogFile(path string, policy LockPolicy) (string, *os.File, error) {
+	lock, err := tryLockFile(path + lockSuffix)
+	if err == nil || !errors.Is(err, ErrLogFileLocked) || policy != LockFallbackPID {
+		return path, lock, err
+	}
+
+	ext := filepath.Ext(path)
+	fallback := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), os.Getpid(), ext)
+	log.Printf("[%s] %s locked by another process, write log to %s", tag, path, fallback)
+	lock, err = tryLockFile(fallback + lockSuffix)
+	return fallback, lock, err
+}
+
+// Release lock taken by lockLogFile(), nil lock is no-op.
+func unlockLogFile(lock *os.File) {
+	if lock != nil {
+		// closing releases the lock, lock file kept to avoid racing with
+		// another process locking it
+		safeClose("lock", lock)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Take exclusive flock of path, created if not exist, returns
+// ErrLogFileLocked if locked by another process.
+func tryLockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil && os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err == nil {
+			f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		safeClose("lock", f)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrLogFileLocked, path)
+		}
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package logging
+
+import (
+	"os"
+)
+
+// Flock not supported, never locked.
+func tryLockFile(path string) (*os.File, error) {
+	return nil, nil
+}
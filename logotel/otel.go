@@ -0,0 +1,117 @@
+// Package logotel records statistics of github.com/redforks/logging writers
+// as OpenTelemetry metric instruments.
+//
+// It is a separate package, so logging package itself does not depend on
+// OpenTelemetry.
+package logotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/redforks/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const instrumentationName = "github.com/redforks/logging"
+
+// Instrumentation implements logging.Instrumentation, records rotation and
+// compression durations, and observes counters from logging.AllWriterStats(),
+// so numbers always agree with expvar and prometheus exporters.
+type Instrumentation struct {
+	rotation    metric.Float64ValueRecorder
+	compression metric.Float64ValueRecorder
+}
+
+// New create Instrumentation using meter from provider.
+func New(provider metric.MeterProvider) (*Instrumentation, error) {
+	meter := provider.Meter(instrumentationName)
+
+	var (
+		err                    error
+		dropped, written       metric.Int64SumObserver
+		innerErrors, rotations metric.Int64SumObserver
+		fileSize, queueDepth   metric.Int64ValueObserver
+	)
+	batch := meter.NewBatchObserver(func(_ context.Context, result metric.BatchObserverResult) {
+		for _, s := range logging.AllWriterStats() {
+			result.Observe(labels(s),
+				dropped.Observation(s.Dropped),
+				written.Observation(s.Written),
+				innerErrors.Observation(s.InnerErrors),
+				rotations.Observation(s.Rotations),
+				fileSize.Observation(s.FileSize),
+				queueDepth.Observation(int64(s.QueueDepth)),
+			)
+		}
+	})
+	if dropped, err = batch.NewInt64SumObserver("logging.dropped",
+		metric.WithDescription("Log messages dropped.")); err != nil {
+		return nil, err
+	}
+	if written, err = batch.NewInt64SumObserver("logging.written",
+		metric.WithDescription("Bytes written by logging writers."), metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if innerErrors, err = batch.NewInt64SumObserver("logging.inner_errors",
+		metric.WithDescription("Errors reported by inner writers.")); err != nil {
+		return nil, err
+	}
+	if rotations, err = batch.NewInt64SumObserver("logging.rotations",
+		metric.WithDescription("Log file rotations.")); err != nil {
+		return nil, err
+	}
+	if fileSize, err = batch.NewInt64ValueObserver("logging.file_size",
+		metric.WithDescription("Current log file size."), metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if queueDepth, err = batch.NewInt64ValueObserver("logging.queue_depth",
+		metric.WithDescription("Log messages waiting in queue.")); err != nil {
+		return nil, err
+	}
+
+	r := &Instrumentation{}
+	if r.rotation, err = meter.NewFloat64ValueRecorder("logging.rotation.duration",
+		metric.WithDescription("Time spent on log file rotation."), metric.WithUnit("ms")); err != nil {
+		return nil, err
+	}
+	if r.compression, err = meter.NewFloat64ValueRecorder("logging.compression.duration",
+		metric.WithDescription("Time spent on archive compression."), metric.WithUnit("ms")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Install create Instrumentation and set it as logging instrumentation.
+func Install(provider metric.MeterProvider) error {
+	inst, err := New(provider)
+	if err != nil {
+		return err
+	}
+	logging.SetInstrumentation(inst)
+	return nil
+}
+
+// Rotated implements logging.Instrumentation.
+func (i *Instrumentation) Rotated(s logging.WriterStats, d time.Duration) {
+	i.rotation.Record(context.Background(), milliseconds(d), labels(s)...)
+}
+
+// Compressed implements logging.Instrumentation.
+func (i *Instrumentation) Compressed(s logging.WriterStats, d time.Duration, err error) {
+	i.compression.Record(context.Background(), milliseconds(d),
+		append(labels(s), attribute.Bool("error", err != nil))...)
+}
+
+func labels(s logging.WriterStats) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("writer", s.ID),
+		attribute.String("sink", s.Kind),
+		attribute.String("file", s.Path),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
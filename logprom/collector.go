@@ -0,0 +1,98 @@
+// Package logprom exports statistics of github.com/redforks/logging writers
+// as prometheus metrics.
+//
+// It is a separate package, so logging package itself does not depend on
+// prometheus client.
+package logprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redforks/logging"
+)
+
+var labels = []string{"writer", "sink", "file"}
+
+var (
+	writtenDesc = prometheus.NewDesc("logging_written_bytes_total",
+		"Bytes written by logging writers.", labels, nil)
+	droppedDesc = prometheus.NewDesc("logging_dropped_total",
+		"Log messages dropped.", labels, nil)
+	rotationsDesc = prometheus.NewDesc("logging_rotations_total",
+		"Log file rotations.", labels, nil)
+	compressFailuresDesc = prometheus.NewDesc("logging_compress_failures_total",
+		"Failed log archive compressions.", labels, nil)
+	innerErrorsDesc = prometheus.NewDesc("logging_inner_errors_total",
+		"Errors reported by inner writers.", labels, nil)
+	fileSizeDesc = prometheus.NewDesc("logging_file_size_bytes",
+		"Current log file size.", labels, nil)
+	queueDepthDesc = prometheus.NewDesc("logging_queue_depth",
+		"Log messages waiting in queue.", labels, nil)
+	openHandlesDesc = prometheus.NewDesc("logging_open_handles",
+		"Open file handles of lazy open file writers.", nil, nil)
+)
+
+// Collector implements prometheus.Collector, collects statistics of all
+// alive writers created by logging package. Writer label is the writer ID,
+// sink label is the writer type, file label is the log file path or spool
+// directory.
+type Collector struct{}
+
+// NewCollector create a new Collector.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// MustRegister create a Collector and register it to prometheus default
+// registerer, panics if failed.
+func MustRegister() {
+	prometheus.MustRegister(NewCollector())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- writtenDesc
+	ch <- droppedDesc
+	ch <- rotationsDesc
+	ch <- compressFailuresDesc
+	ch <- innerErrorsDesc
+	ch <- fileSizeDesc
+	ch <- queueDepthDesc
+	ch <- openHandlesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// writers may have the same labels, such as two writers named the same,
+	// merge them, prometheus rejects duplicate metrics.
+	type key struct{ writer, sink, file string }
+	var keys []key
+	merged := map[key]*logging.WriterStats{}
+	for _, s := range logging.AllWriterStats() {
+		k := key{s.ID, s.Kind, s.Path}
+		m, ok := merged[k]
+		if !ok {
+			keys = append(keys, k)
+			m = &logging.WriterStats{}
+			merged[k] = m
+		}
+		m.Written += s.Written
+		m.Dropped += s.Dropped
+		m.Rotations += s.Rotations
+		m.CompressFailures += s.CompressFailures
+		m.InnerErrors += s.InnerErrors
+		m.FileSize += s.FileSize
+		m.QueueDepth += s.QueueDepth
+	}
+
+	for _, k := range keys {
+		s := merged[k]
+		ch <- prometheus.MustNewConstMetric(writtenDesc, prometheus.CounterValue, float64(s.Written), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.Dropped), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(rotationsDesc, prometheus.CounterValue, float64(s.Rotations), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(compressFailuresDesc, prometheus.CounterValue, float64(s.CompressFailures), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(innerErrorsDesc, prometheus.CounterValue, float64(s.InnerErrors), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(fileSizeDesc, prometheus.GaugeValue, float64(s.FileSize), k.writer, k.sink, k.file)
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(s.QueueDepth), k.writer, k.sink, k.file)
+	}
+	ch <- prometheus.MustNewConstMetric(openHandlesDesc, prometheus.GaugeValue, float64(logging.OpenHandles()))
+}
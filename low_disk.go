@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/redforks/hal"
+)
+
+// LowDiskAction is what file log writer does if free disk space below
+// threshold, see WithLowDiskPolicy().
+type LowDiskAction int
+
+const (
+	// LowDiskPrune deletes oldest half of compressed archives on each check
+	// until free space recovered, beyond normal retention.
+	LowDiskPrune LowDiskAction = iota + 1
+
+	// LowDiskPauseCompression leaves new backups uncompressed, compressed
+	// after free space recovered.
+	LowDiskPauseCompression
+
+	// LowDiskDrop drops records, counted as dropped messages, instead of
+	// writing to the log file.
+	LowDiskDrop
+)
+
+func (a LowDiskAction) String() string {
+	switch a {
+	case LowDiskPrune:
+		return "prune archives"
+	case LowDiskPauseCompression:
+		return "pause compression"
+	case LowDiskDrop:
+		return "drop records"
+	default:
+		return fmt.Sprintf("LowDiskAction(%d)", int(a))
+	}
+}
+
+// WithLowDiskPolicy makes file log writer take action if free space of the
+// log file system below minFree bytes, checked every 10 seconds on write, so
+// the disk never fills up. Reports an internal error when free space drops
+// below minFree. No-op on platforms not support statfs, such as windows.
+func WithLowDiskPolicy(minFree int64, action LowDiskAction) Option {
+	return func(o *writerOptions) {
+		o.lowDiskFree, o.lowDiskAction = minFree, action
+	}
+}
+
+// Check free space periodically and apply low disk action, returns true if
+// the record should be dropped. Must hold w.l.
+func (w *fileLogWriter) checkLowDisk() bool {
+	if now := hal.Now(); now.Sub(w.diskCheckedAt) >= statPeriod {
+		w.diskCheckedAt = now
+		w.updateLowDisk()
+	}
+	return w.lowDiskAction == LowDiskDrop && atomic.LoadInt32(&w.lowDisk) == 1
+}
+
+// Must hold w.l.
+func (w *fileLogWriter) updateLowDisk() {
+	dir := filepath.Dir(w.CurrentPath())
+	free, ok, err := diskFree(dir)
+	if err != nil {
+		w.reportError("disk", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if debugEnabled() {
+		debugf("disk: %s free %d bytes, min %d", dir, free, w.lowDiskFree)
+	}
+
+	if free >= w.lowDiskFree {
+		if atomic.CompareAndSwapInt32(&w.lowDisk, 1, 0) {
+			if debugEnabled() {
+				debugf("disk: %s free space recovered", dir)
+			}
+			if w.lowDiskAction == LowDiskPauseCompression {
+				w.enqueueJob("resume", func() {
+					w.recoverPartialCompressFiles(w.CurrentPath())
+				})
+			}
+		}
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&w.lowDisk, 0, 1) {
+		w.reportError("disk", fmt.Errorf("free space of %s %d bytes below %d, %s", dir, free, w.lowDiskFree, w.lowDiskAction))
+	}
+	if w.lowDiskAction == LowDiskPrune {
+		w.enqueuePurgeForSpace()
+	}
+}
+
+// Returns true if compression paused by low disk space.
+func (w *fileLogWriter) compressionPaused() bool {
+	return w.lowDiskAction == LowDiskPauseCompression && atomic.LoadInt32(&w.lowDisk) == 1
+}
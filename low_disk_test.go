@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Pruning with secure delete runs on the background worker, writes not
+// blocked by throttled overwrite.
+func TestLowDiskPruneNotBlockWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := openTestFileWriter(t, path, 0, 0)
+	rotateTimes(t, w, 2)
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := diskFree(filepath.Dir(path)); !ok {
+		t.Skip("free space unknown on this platform")
+	}
+
+	// always low, each archive overwritten about a second
+	rate := archives[0].Size
+	w = openTestFileWriter(t, path, 0, 0, WithLowDiskPolicy(1<<62, LowDiskPrune), WithSecureDelete(0, rate))
+	start := time.Now()
+	if _, err := w.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("write blocked %s by pruning", d)
+	}
+
+	w.waitBackground()
+	left, err := w.getCompressedFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// oldest half, at least one, deleted
+	if len(left) != len(archives)/2 {
+		t.Errorf("left %v of %d archives", left, len(archives))
+	}
+}
@@ -0,0 +1,233 @@
+package logging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// WithManifest makes file log writer maintain "<logfile>.manifest.json" next
+// to archives, listing each archive with its backup name, creation time,
+// uncompressed and compressed size, and sha256. The manifest rewritten
+// atomically after each compression and retention, and reconciled with
+// archives on disk on start: entries of missing archives dropped, archives
+// not listed added, their uncompressed size unknown. VerifyArchives()
+// validates archives against checksums of the manifest.
+func WithManifest() Option {
+	return func(o *writerOptions) {
+		o.manifest = true
+	}
+}
+
+const manifestSuffix = `.manifest.json`
+
+// Archive entry of manifest.
+type manifestEntry struct {
+	Archive        string    `json:"archive"`
+	Backup         string    `json:"backup,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	Size           int64     `json:"size,omitempty"` // uncompressed, 0 if unknown
+	CompressedSize int64     `json:"compressed_size"`
+	SHA256         string    `json:"sha256"`
+}
+
+type manifestFile struct {
+	Archives []manifestEntry `json:"archives"`
+}
+
+// Returns path of the manifest, next to archives of the log file, the non
+// dated path if dated.
+func (w *fileLogWriter) manifestPath() string {
+	return w.archivePath(w.linkBase()) + manifestSuffix
+}
+
+// Returns archive path to entry map of manifest file, false if not exist.
+// Unreadable manifest treated as empty, rebuilt by reconciliation.
+func readManifest(path string) (map[string]manifestEntry, bool) {
+	r := map[string]manifestEntry{}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return r, !os.IsNotExist(err)
+	}
+
+	var m manifestFile
+	if err := json.Unmarshal(content, &m); err != nil {
+		reportError("manifest", err)
+		return r, true
+	}
+	for _, e := range m.Archives {
+		r[e.Archive] = e
+	}
+	return r, true
+}
+
+// Write entries to a temp file then rename, oldest first.
+func writeManifest(path string, entries map[string]manifestEntry, mode os.FileMode) error {
+	m := manifestFile{Archives: make([]manifestEntry, 0, len(entries))}
+	for _, e := range entries {
+		m.Archives = append(m.Archives, e)
+	}
+	sort.Slice(m.Archives, func(i, j int) bool {
+		a, b := m.Archives[i], m.Archives[j]
+		if a.CreatedAt.Equal(b.CreatedAt) {
+			return a.Archive < b.Archive
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + `.tmp`
+	if err = ioutil.WriteFile(tmp, append(content, '\n'), mode); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = syncFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Apply fn to entries of manifest, rewrite the manifest if fn returns true
+// or manifest not exist. No-op if manifest not enabled.
+func (w *fileLogWriter) updateManifest(fn func(entries map[string]manifestEntry) bool) {
+	if !w.manifest {
+		return
+	}
+
+	w.manifestL.Lock()
+	defer w.manifestL.Unlock()
+	path := w.manifestPath()
+	entries, exist := readManifest(path)
+	if !fn(entries) && exist {
+		return
+	}
+	if err := writeManifest(path, entries, w.createMode()); err != nil {
+		w.reportError("manifest", err)
+	}
+}
+
+// Returns manifest entry of archive file, false if archive unreadable.
+func newManifestEntry(archive string, createdAt time.Time) (manifestEntry, bool) {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return manifestEntry{}, false
+	}
+	sum := fileChecksum(archive)
+	if sum == "" {
+		return manifestEntry{}, false
+	}
+	return manifestEntry{
+		Archive:        archive,
+		CreatedAt:      createdAt,
+		CompressedSize: info.Size(),
+		SHA256:         sum,
+	}, true
+}
+
+// Add archive compressed from bakFile of size to manifest.
+func (w *fileLogWriter) manifestAdded(bakFile, archive string, size int64) {
+	w.updateManifest(func(entries map[string]manifestEntry) bool {
+		e, ok := newManifestEntry(archive, hal.Now())
+		if !ok {
+			return false
+		}
+		e.Backup, e.Size = filepath.Base(bakFile), size
+		entries[archive] = e
+		return true
+	})
+}
+
+// Move manifest entry of renamed archive, such as numbered backups shifted
+// on rotation.
+func (w *fileLogWriter) manifestRenamed(from, to string) {
+	w.updateManifest(func(entries map[string]manifestEntry) bool {
+		e, ok := entries[from]
+		if !ok {
+			return false
+		}
+		delete(entries, from)
+		e.Archive, e.Backup = to, filepath.Base(w.trimArchiveSuffix(to))
+		entries[to] = e
+		return true
+	})
+}
+
+// Drop manifest entries of archives not exist, such as deleted by retention,
+// add archives not listed, such as compressed before manifest enabled.
+func (w *fileLogWriter) reconcileManifest() {
+	if !w.manifest {
+		return
+	}
+
+	// listed before locking manifest, listArchives() reads checksums of it
+	archives, err := w.listArchives()
+	if err != nil {
+		w.reportError("manifest", err)
+		return
+	}
+	w.updateManifest(func(entries map[string]manifestEntry) bool {
+		changed := false
+		for _, a := range archives {
+			if !a.Compressed {
+				continue
+			}
+			if _, ok := entries[a.Path]; ok {
+				continue
+			}
+			if e, ok := newManifestEntry(a.Path, a.RotatedAt); ok {
+				e.Backup = filepath.Base(w.trimArchiveSuffix(a.Path))
+				entries[a.Path] = e
+				changed = true
+			}
+		}
+		// checked on disk, archives may be deleted or renamed after listed
+		for f := range entries {
+			if _, err := os.Stat(f); os.IsNotExist(err) {
+				delete(entries, f)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// Returns archive path to checksum map recorded in manifest, nil if manifest
+// not enabled.
+func (w *fileLogWriter) manifestChecksums() map[string]string {
+	if !w.manifest {
+		return nil
+	}
+
+	w.manifestL.Lock()
+	entries, _ := readManifest(w.manifestPath())
+	w.manifestL.Unlock()
+	r := make(map[string]string, len(entries))
+	for f, e := range entries {
+		r[f] = e.SHA256
+	}
+	return r
+}
+
+// Returns archive path to checksum map of audit log and manifest, manifest
+// preferred.
+func (w *fileLogWriter) archiveChecksums(logfile string) map[string]string {
+	r := auditChecksums(logfile)
+	for f, sum := range w.manifestChecksums() {
+		r[f] = sum
+	}
+	return r
+}
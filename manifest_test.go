@@ -0,0 +1,163 @@
+package logging
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestCompressed(t *testing.T) {
+	w := newTestFileWriter(t, 0, 0, WithManifest())
+	rotateTimes(t, w, 3)
+
+	entries, exist := readManifest(w.CurrentPath() + manifestSuffix)
+	if !exist {
+		t.Fatal("manifest not written")
+	}
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || len(archives) != 3 {
+		t.Fatalf("manifest entries %d, archives %d, want 3", len(entries), len(archives))
+	}
+	for _, a := range archives {
+		e, ok := entries[a.Path]
+		switch {
+		case !ok:
+			t.Errorf("%s not in manifest", a.Path)
+		case e.SHA256 != fileChecksum(a.Path):
+			t.Errorf("%s checksum %s, want %s", a.Path, e.SHA256, fileChecksum(a.Path))
+		case e.CompressedSize != a.Size:
+			t.Errorf("%s compressed size %d, want %d", a.Path, e.CompressedSize, a.Size)
+		case e.Size != int64(len("line 0\n")):
+			t.Errorf("%s size %d", a.Path, e.Size)
+		case e.Backup != filepath.Base(w.trimArchiveSuffix(a.Path)):
+			t.Errorf("%s backup %s", a.Path, e.Backup)
+		case e.CreatedAt.IsZero():
+			t.Errorf("%s creation time not set", a.Path)
+		}
+	}
+	if _, err := os.Stat(w.CurrentPath() + manifestSuffix + `.tmp`); !os.IsNotExist(err) {
+		t.Errorf("temp manifest left: %v", err)
+	}
+}
+
+func TestManifestReconcile(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		remove func(t *testing.T, w *fileLogWriter)
+		want   int
+	}{
+		{"retention", []Option{WithMaxFiles(2)}, func(*testing.T, *fileLogWriter) {}, 2},
+		{"purge all", nil, func(t *testing.T, w *fileLogWriter) {
+			if _, err := w.PurgeAll(); err != nil {
+				t.Fatal(err)
+			}
+		}, 0},
+		{"numbered", []Option{WithNumberedBackups(), WithMaxFiles(2)}, func(*testing.T, *fileLogWriter) {}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestFileWriter(t, 0, 0, append(tt.opts, WithManifest())...)
+			rotateTimes(t, w, 4)
+			tt.remove(t, w)
+
+			entries, _ := readManifest(w.CurrentPath() + manifestSuffix)
+			if len(entries) != tt.want {
+				t.Fatalf("manifest entries %d, want %d", len(entries), tt.want)
+			}
+			for f, e := range entries {
+				if e.SHA256 != fileChecksum(f) {
+					t.Errorf("%s checksum %s, want %s", f, e.SHA256, fileChecksum(f))
+				}
+			}
+		})
+	}
+}
+
+func TestManifestReconcileOnStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := openTestFileWriter(t, path, 0, 0)
+	rotateTimes(t, w, 3)
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// archives compressed before manifest enabled added
+	w = openTestFileWriter(t, path, 0, 0, WithManifest())
+	w.waitBackground()
+	entries, _ := readManifest(path + manifestSuffix)
+	if len(entries) != 3 {
+		t.Fatalf("manifest entries %d, want 3", len(entries))
+	}
+	for _, a := range archives {
+		if e := entries[a.Path]; e.SHA256 != fileChecksum(a.Path) || e.Size != 0 {
+			t.Errorf("%s entry %+v", a.Path, e)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// entries of missing archives dropped
+	if err := os.Remove(archives[0].Path); err != nil {
+		t.Fatal(err)
+	}
+	w = openTestFileWriter(t, path, 0, 0, WithManifest())
+	w.waitBackground()
+	entries, _ = readManifest(path + manifestSuffix)
+	if _, ok := entries[archives[0].Path]; ok || len(entries) != 2 {
+		t.Errorf("entries %v, want %s dropped", entries, archives[0].Path)
+	}
+}
+
+func TestVerifyArchivesManifest(t *testing.T) {
+	w := newTestFileWriter(t, 0, 0, WithManifest())
+	rotateTimes(t, w, 2)
+	archives, err := w.listArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaced by a valid archive of other content, found by checksum only
+	tampered := archives[0].Path
+	f, err := os.Create(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := gzip.NewWriter(f)
+	if _, err := gw.Write([]byte("forged line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := w.VerifyArchives(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("results %d, want 2", len(results))
+	}
+	for _, r := range results {
+		want, wantErr := VerifyOK, error(nil)
+		if r.Path == tampered {
+			want, wantErr = VerifyCorrupt, errChecksumMismatch
+		}
+		if r.Status != want || r.Err != wantErr {
+			t.Errorf("%s status %v, err %v, want %v, %v", r.Path, r.Status, r.Err, want, wantErr)
+		}
+	}
+}
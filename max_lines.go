@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/redforks/hal"
+)
+
+// WithMaxLines makes file log writer rotate log file if it has n lines, such
+// as one record per line and at most n records per file, combined with maxLen
+// and other triggers, whichever comes first. Log file rotated before a write
+// would exceed n lines, a write of more than n lines written whole to the
+// fresh file. Lines counted by newlines, a write without trailing newline
+// counted with the record completes it. Lines of existing log file counted
+// by scanning it on open and reopen, header lines not counted. Zero, the
+// default, not rotate by lines.
+func WithMaxLines(n int64) Option {
+	return func(o *writerOptions) {
+		o.maxLines = n
+	}
+}
+
+// Returns true if the live log file reached maxLines, false if not rotate by
+// lines. Must hold w.l.
+func (w *fileLogWriter) linesDue() bool {
+	return w.maxLines > 0 && w.lineCount >= w.maxLines
+}
+
+// Rotate if writing lines would exceed maxLines. Must hold w.l.
+func (w *fileLogWriter) rotateBeforeLinesLocked(lines int64) error {
+	if w.lineCount == 0 || w.lineCount+lines <= w.maxLines {
+		return nil
+	}
+	if w.rotateTooSoon(hal.Now()) {
+		return nil
+	}
+
+	bakFile := w.newBackupFilename(w.f.Name())
+	if debugEnabled() {
+		debugf("rotate: %s lines %d, write %d lines would exceed maxLines %d, backup to %s", w.CurrentPath(), w.lineCount, lines, w.maxLines, bakFile)
+	}
+	return w.rotateLocked(hal.Now(), bakFile, atomic.LoadInt64(&w.currentSize))
+}
+
+// Count lines of the live log file, if rotate by lines. Must hold w.l.
+func (w *fileLogWriter) resetLineCount() {
+	if w.maxLines <= 0 {
+		return
+	}
+	n, err := countFileLines(w.CurrentPath())
+	if err != nil && !os.IsNotExist(err) {
+		w.reportError("file", err)
+	}
+	w.lineCount = n
+}
+
+func countFileLines(path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer safeClose("file", f)
+
+	var n int64
+	buf := make([]byte, 32*1024)
+	for {
+		m, err := f.Read(buf)
+		n += int64(bytes.Count(buf[:m], newline))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+var newline = []byte{'\n'}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMaxLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxLen   int64
+		header   string
+		existing string // content of log file left by last run
+		writes   []string
+		archives []string
+		live     string
+	}{
+		{"line per write", 0, "", "", []string{"a\n", "b\n", "c\n", "d\n"}, []string{"a\nb\nc\n"}, "d\n"},
+		{"multiple lines", 0, "", "", []string{"a\nb\n", "c\nd\n"}, []string{"a\nb\n"}, "c\nd\n"},
+		{"no trailing newline", 0, "", "", []string{"a\nb", "c\n", "d\n"}, []string{"a\nbc\nd\n"}, ""},
+		{"more than max lines", 0, "", "", []string{"a\n", "1\n2\n3\n4\n", "b\n"}, []string{"a\n", "1\n2\n3\n4\n"}, "b\n"},
+		{"max len first", 4, "", "", []string{"ab\n", "cd\n"}, []string{"ab\n"}, "cd\n"},
+		{"header not counted", 0, "# header\n", "", []string{"a\n", "b\n", "c\n", "d\n"},
+			[]string{"# header\na\nb\nc\n"}, "# header\nd\n"},
+		{"counted on open", 0, "", "a\nb\n", []string{"c\n", "d\n"}, []string{"a\nb\nc\n"}, "d\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			if tt.existing != "" {
+				if err := ioutil.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			opts := []Option{WithMaxLines(3)}
+			if tt.header != "" {
+				opts = append(opts, WithHeader(func() []byte { return []byte(tt.header) }))
+			}
+			w := openTestFileWriter(t, path, tt.maxLen, 0, opts...)
+			for _, s := range tt.writes {
+				if _, err := w.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := archiveContents(t, w); !reflect.DeepEqual(got, tt.archives) {
+				t.Errorf("archives %q, want %q", got, tt.archives)
+			}
+			live, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(live) != tt.live {
+				t.Errorf("live log file %q, want %q", live, tt.live)
+			}
+		})
+	}
+}
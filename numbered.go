@@ -0,0 +1,208 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithNumberedBackups names backups logrotate style instead of by rotation
+// time: the newest backup is `app.log.1`, older ones shifted up on each
+// rotation, such as `app.log.2.gz`, backups numbered greater than maxFiles
+// deleted.
+//
+// Backups of both naming are recognized, compressed and cleaned by
+// retention regardless of the option, so switching naming on an existing
+// log directory not orphan old backups. Numbered backups are renamed on
+// rotation, a path returned by such as ListArchives() may refer to another
+// backup after next rotation.
+func WithNumberedBackups() Option {
+	return func(o *writerOptions) {
+		o.numbered = true
+	}
+}
+
+// Parse number of numbered backup name, rest is the part after number, such
+// as `.gz`.
+func (w *fileLogWriter) backupNumber(logfilename, name string) (n int, rest string, ok bool) {
+	prefix := w.archivePath(logfilename) + `.`
+	if !strings.HasPrefix(name, prefix) {
+		return 0, "", false
+	}
+	s := name[len(prefix):]
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		i = len(s)
+	}
+	if i == 0 || strings.Trim(s[:i], "0123456789") != "" {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n == 0 {
+		return 0, "", false
+	}
+	// time layout of digits only, such as `20060102150405`
+	if _, isTime := w.nativeBackupTime(logfilename, name[:len(prefix)+i]); isTime {
+		return 0, "", false
+	}
+	return n, s[i:], true
+}
+
+// Returns numbered backups with the extra suffix, such as `.gz`, not sorted.
+func (w *fileLogWriter) getNumberedFiles(logfilename, suffix string) ([]string, error) {
+	matches, err := filepath.Glob(globEscape(w.archivePath(logfilename)) + `.*` + globEscape(suffix))
+	if err != nil {
+		return nil, err
+	}
+
+	files := matches[:0]
+	for _, f := range matches {
+		if _, rest, ok := w.backupNumber(logfilename, f); ok && rest == suffix {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Number staged backups named by time, oldest first, and compress them.
+// Called in background after rotation.
+func (w *fileLogWriter) archiveNumbered(logfilename string) error {
+	w.renumberL.Lock()
+	defer w.renumberL.Unlock()
+
+	staged, err := w.getTimestampFiles(logfilename, ``)
+	if err != nil {
+		return err
+	}
+	w.sortBackups(logfilename, staged)
+
+	for _, f := range staged {
+		info, err := os.Stat(f)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// purged
+				continue
+			}
+			return err
+		}
+		rotatedAt := w.backupTime(logfilename, f)
+
+		if err = w.shiftNumbered(logfilename); err != nil {
+			return err
+		}
+		first := w.archivePath(logfilename) + `.1`
+		if err = w.renameBackup(f, first); err != nil {
+			return err
+		}
+		if w.keepUncompressed > 0 {
+			continue
+		}
+		if err = w.archive(first, rotatedAt, info.Size()); err != nil {
+			return err
+		}
+	}
+	if w.keepUncompressed > 0 {
+		return w.compressOutsideWindow(logfilename)
+	}
+	return nil
+}
+
+// Renumber numbered backups to make room for `.1`, backups would be
+// numbered greater than maxFiles deleted.
+func (w *fileLogWriter) shiftNumbered(logfilename string) error {
+	matches, err := filepath.Glob(globEscape(w.archivePath(logfilename)) + `.*`)
+	if err != nil {
+		return err
+	}
+
+	type numbered struct {
+		name, rest string
+		n          int
+	}
+	var files []numbered
+	for _, f := range matches {
+		if n, rest, ok := w.backupNumber(logfilename, f); ok {
+			files = append(files, numbered{f, rest, n})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].n > files[j].n
+	})
+
+	for _, f := range files {
+		if w.maxFiles > 0 && f.n >= w.maxFiles {
+			if isSidecar(f.name) {
+				if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+
+			var size int64
+			if info, err := os.Stat(f.name); err == nil {
+				size = info.Size()
+			}
+			if err := w.removeArchive(f.name); err != nil {
+				return err
+			}
+			w.audit.record(auditEntry{Action: auditDelete, File: f.name, Reason: reasonCount, SizeBefore: size})
+			if debugEnabled() {
+				debugf("retention: deleted %s", f.name)
+			}
+			continue
+		}
+
+		target := w.archivePath(logfilename) + `.` + strconv.Itoa(f.n+1) + f.rest
+		if err := w.renameBackup(f.name, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *fileLogWriter) renameBackup(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	if !isSidecar(from) {
+		w.compressRenamed(from, to)
+		w.manifestRenamed(from, to)
+		w.audit.record(auditEntry{Action: auditRename, File: from, Target: to})
+	}
+	return nil
+}
+
+// Compress numbered backups left uncompressed by last run, then number and
+// compress backups named by time, in one background job.
+func (w *fileLogWriter) recoverNumbered(logfilename string) {
+	w.enqueueJob("", func() {
+		w.renumberL.Lock()
+		uncompressed, err := w.getNumberedFiles(logfilename, ``)
+		if err != nil {
+			w.reportError("compress", err)
+		}
+		if w.keepUncompressed > 0 {
+			// compressed by archiveNumbered(), except the most recent
+			uncompressed = nil
+		}
+		for _, f := range uncompressed {
+			info, err := os.Stat(f)
+			if err != nil {
+				w.reportError("compress", err)
+				continue
+			}
+			w.audit.record(auditEntry{Action: auditRecover, File: f, SizeBefore: info.Size()})
+			if err := w.archive(f, info.ModTime(), info.Size()); err != nil {
+				w.reportError("compress", err)
+			}
+		}
+		w.renumberL.Unlock()
+
+		if err := w.archiveNumbered(logfilename); err != nil {
+			w.reportError("compress", err)
+		}
+		w.enqueueCleanup(logfilename)
+	})
+}
@@ -11,9 +11,11 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/redforks/appinfo"
 	"github.com/redforks/config"
+	"github.com/redforks/testing/reset"
 )
 
 const (
@@ -29,12 +31,42 @@ type option struct {
 	ToConsole bool // if true, also log to stderr
 	ToFile    bool // if true, enable Async log file
 
-	LogFile          string // if "", use /var/log/[AppName].log
-	MaxLogFileLen    int64  // max log file size, if reached, rename and create new file. Old file compressed
-	MaxArchivedFiles int    // How many compressed file kept.
+	LogFile          string   // if "", use /var/log/[AppName].log
+	ArchiveDir       string   // directory of archived files, if "", next to LogFile
+	MaxLogFileLen    int64    // max log file size, if reached, rename and create new file. Old file compressed. 0 to disable
+	MaxArchivedFiles int      // How many compressed file kept, 0 to keep all.
+	RotateEvery      Duration // rotate log file after the interval even not reach MaxLogFileLen, 0 to disable
+	MaxLines         int64    // max lines of log file, rotated if reached, 0 to disable
+	FreshStart       bool     // if true, rotate existing log file on start, each run gets its own log file
+	CompressOnClose  bool     // if true, rotate and compress log file on shutdown, no plain log file left
+	Manifest         bool     // if true, maintain [LogFile].manifest.json of archives with checksums
+	BackupUTC        bool     // if true, backup file names use UTC time, such as app-2024-05-01-150405Z.log
+	KeepUncompressed int      // most recent archived files left uncompressed, 0 to compress all
+	CopyTruncate     bool     // if true, rotate by copy then truncate log file in place, for log file can not be renamed
+	MaxTotalSize     int64    // max total size of archived files, oldest deleted if exceeded, 0 to disable
+	MaxAge           Duration // archived files older than MaxAge deleted, 0 to disable
+	FileMode         FileMode // permission of created log files, such as "0640", default to 0640
+	Header           bool     // if true, write app, version, pid and host at top of each new log file
+
+	StatusInterval Duration // interval to log a status line of logging statistics, 0 to disable
+	SyncOnError    bool     // fsync log file after error and above records, see WithSyncOnError()
+	SyncInterval   Duration // fsync log file periodically if written, 0 to disable
+	BufferSize     int      // buffer records in memory of the size, flushed every 200ms, 0 to disable
+	CloseTimeout   Duration // max wait of background compression on close, default to 10s
 }
 
+// Options applied by config, and resolved paths, for DumpState.
+var (
+	currentOption  *option
+	currentLogFile string
+)
+
 func (o *option) Init() error {
+	registryLock.Lock()
+	currentOption = o
+	prevAsync := defaultAsyncWriter
+	registryLock.Unlock()
+
 	var writers []io.Writer
 	if o.ToConsole {
 		writers = append(writers, os.Stdout)
@@ -44,14 +76,79 @@ func (o *option) Init() error {
 		if fn == "" {
 			fn = filepath.Join(GetLogDir(), appinfo.CodeName()+".log")
 		}
-		w, err := NewFileLogWriter(fn, o.MaxLogFileLen, o.MaxArchivedFiles)
+		var opts []Option
+		if o.SyncOnError {
+			opts = append(opts, WithSyncOnError())
+		}
+		if o.CloseTimeout > 0 {
+			opts = append(opts, WithCloseTimeout(time.Duration(o.CloseTimeout)))
+		}
+		if o.ArchiveDir != "" {
+			opts = append(opts, WithArchiveDir(o.ArchiveDir))
+		}
+		if o.BufferSize > 0 {
+			opts = append(opts, WithBuffer(o.BufferSize, 0))
+		}
+		if o.SyncInterval > 0 {
+			opts = append(opts, WithSyncInterval(time.Duration(o.SyncInterval)))
+		}
+		if o.MaxLines > 0 {
+			opts = append(opts, WithMaxLines(o.MaxLines))
+		}
+		if o.RotateEvery > 0 {
+			opts = append(opts, WithRotateEvery(time.Duration(o.RotateEvery)))
+		}
+		if o.FreshStart {
+			opts = append(opts, WithFreshStart())
+		}
+		if o.CompressOnClose {
+			opts = append(opts, WithCompressOnClose())
+		}
+		if o.Manifest {
+			opts = append(opts, WithManifest())
+		}
+		if o.BackupUTC {
+			opts = append(opts, WithBackupUTC())
+		}
+		if o.CopyTruncate {
+			opts = append(opts, WithCopyTruncate())
+		}
+		if o.KeepUncompressed > 0 {
+			opts = append(opts, WithKeepUncompressed(o.KeepUncompressed))
+		}
+		if o.MaxTotalSize > 0 {
+			opts = append(opts, WithMaxTotalSize(o.MaxTotalSize))
+		}
+		if o.MaxAge > 0 {
+			opts = append(opts, WithMaxAge(time.Duration(o.MaxAge)))
+		}
+		if o.FileMode != 0 {
+			opts = append(opts, WithFileMode(os.FileMode(o.FileMode)))
+		}
+		if o.Header {
+			opts = append(opts, WithHeader(DefaultHeader))
+		}
+		w, err := NewFileLogWriter(fn, o.MaxLogFileLen, o.MaxArchivedFiles, opts...)
 		if err != nil {
 			return err
 		}
 
-		log.Printf("[%s] write log to %s", tag, o.LogFile)
-		w = NewAsyncLogWriter(w)
-		writers = append(writers, w)
+		log.Printf("[%s] write log to %s", tag, fn)
+		if o.MaxLogFileLen <= 0 && o.MaxLines <= 0 && o.RotateEvery <= 0 {
+			log.Printf("[%s] no rotation trigger of %s, log file grows without limit, set MaxLogFileLen, MaxLines or RotateEvery", tag, fn)
+		}
+		async := NewAsyncLogWriter(w, opts...)
+		registryLock.Lock()
+		defaultFileWriter, defaultAsyncWriter = w.(*fileLogWriter), async.(*asyncLogWriter)
+		currentLogFile = fn
+		registryLock.Unlock()
+		writers = append(writers, async)
+	} else {
+		// writers of previous Init closed below, not reported any more
+		registryLock.Lock()
+		defaultFileWriter, defaultAsyncWriter = nil, nil
+		currentLogFile = ""
+		registryLock.Unlock()
 	}
 
 	var w io.Writer
@@ -63,9 +160,29 @@ func (o *option) Init() error {
 		w = io.MultiWriter(writers...)
 	}
 
+	if w == nil && prevAsync != nil {
+		// not write to the async writer closed below
+		w = os.Stderr
+	}
 	if w != nil {
 		log.SetOutput(w)
 	}
+	// re-initialized, close writers of previous Init, cascade to its file log
+	// writer
+	registryLock.Lock()
+	curAsync := defaultAsyncWriter
+	registryLock.Unlock()
+	if prevAsync != nil && prevAsync != curAsync {
+		if err := prevAsync.Close(); err != nil {
+			reportError("config", err)
+		}
+	}
+
+	var status time.Duration
+	if o.ToFile && !reset.TestMode() {
+		status = time.Duration(o.StatusInterval)
+	}
+	startStatus(status, curAsync)
 	return nil
 }
 
@@ -80,6 +197,7 @@ func init() {
 			ToFile:           true,
 			MaxLogFileLen:    256 * 1024 * 1024,
 			MaxArchivedFiles: 5,
+			StatusInterval:   Duration(time.Hour),
 		}
 	})
 }
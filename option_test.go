@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/redforks/testing/reset"
+)
+
+// Re-initialized without log file, writers of previous Init closed, not
+// reported by Stats().
+func TestInitWithoutFile(t *testing.T) {
+	reset.Enable()
+	defer reset.Disable()
+	out := log.Writer()
+	defer log.SetOutput(out)
+
+	o := &option{ToFile: true, LogFile: filepath.Join(t.TempDir(), "app.log"), MaxLogFileLen: 1 << 20}
+	if err := o.Init(); err != nil {
+		t.Fatal(err)
+	}
+	w, async := defaultWriters()
+	if w == nil || async == nil {
+		t.Fatal("writers not created")
+	}
+	if _, err := Stats(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&option{ToConsole: true}).Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Stats(); !errors.Is(err, ErrNoFileLogWriter) {
+		t.Errorf("stats of closed writer, err %v", err)
+	}
+	if _, err := w.Write([]byte("line\n")); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("write to file log writer of previous Init, err %v", err)
+	}
+	registryLock.Lock()
+	stopped := statusStop == nil
+	registryLock.Unlock()
+	if !stopped {
+		t.Error("status loop not stopped")
+	}
+}
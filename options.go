@@ -0,0 +1,225 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Option configures writers created by this package, such as
+// NewFileLogWriter, NewAsyncLogWriter. Options not apply to the writer type
+// are ignored.
+type Option func(o *writerOptions)
+
+// All settings of writers, each writer type uses its own part.
+type writerOptions struct {
+	noRegistry bool
+	name       string
+
+	// fileLogWriter
+	maxLen       int64
+	maxFiles     int
+	auditMaxSize int64
+	adopt        []adoptPattern
+	encryptKey   KeyFunc
+	signKey      KeyFunc
+
+	secureDelete     bool
+	secureDeleteMax  int64
+	secureDeleteRate int64
+
+	emergencyPurge  bool
+	fileMode        os.FileMode
+	uid, gid        int
+	strictPerms     bool
+	lazyOpen        bool
+	rotateEvery     time.Duration
+	calendar        *calendarRotation
+	freshStart      bool
+	backupLayout    string
+	backupAfterExt  bool
+	backupUTC       bool
+	backupHost      bool
+	backupPID       bool
+	backupName      BackupNameFunc
+	isBackup        func(name string) bool
+	numbered        bool
+	compressor      Compressor
+	gzipLevel       *int // nil if not set
+	gzipConcurrency int
+	compressRate    int64
+	maxTotalSize    int64
+	maxAge          time.Duration
+	currentLink     string
+	currentLinkOn   bool
+	header          HeaderFunc
+	syncEvery       int64
+	syncInterval    time.Duration
+	bufferSize      int
+	flushInterval   time.Duration
+	archiveDir      string
+	onRotate        func(backup, archive string)
+	rotationEvents  chan<- RotationEvent
+	bundleAge       time.Duration
+	closeTimeout    time.Duration
+	lockPolicy      LockPolicy
+
+	minRotateInterval time.Duration
+
+	keepUncompressed int
+	copyTruncate     bool
+	maxLines         int64
+	compressOnClose  bool
+	manifest         bool
+
+	lowDiskFree   int64
+	lowDiskAction LowDiskAction
+
+	// asyncLogWriter
+	walPath     string
+	walMaxBytes int64
+
+	// fileLogWriter, asyncLogWriter
+	syncOnError bool
+
+	// FailoverWriter
+	failoverThreshold time.Duration
+	probeInterval     time.Duration
+}
+
+func newWriterOptions(opts []Option) *writerOptions {
+	r := &writerOptions{
+		maxLen:            defaultMaxLen,
+		maxFiles:          defaultMaxFiles,
+		backupLayout:      backupTimeLayout,
+		closeTimeout:      defaultCloseTimeout,
+		uid:               -1,
+		gid:               -1,
+		failoverThreshold: time.Minute,
+		probeInterval:     10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Defaults of NewFileWriter().
+const (
+	defaultMaxLen   = 256 * 1024 * 1024
+	defaultMaxFiles = 5
+)
+
+// WithMaxLen set max length of log file, rotated before exceeds maxLen, a
+// record larger than maxLen written whole to its own file, default to 256MB.
+// Not rotated by size if maxLen <= 0.
+func WithMaxLen(maxLen int64) Option {
+	return func(o *writerOptions) {
+		o.maxLen = maxLen
+	}
+}
+
+// WithMaxFiles set how many archives kept, oldest deleted, default to 5. All
+// archives kept if maxFiles <= 0.
+func WithMaxFiles(maxFiles int) Option {
+	return func(o *writerOptions) {
+		o.maxFiles = maxFiles
+	}
+}
+
+// Returns error if file log writer options invalid or conflict.
+func validateFileOptions(path string, o *writerOptions) error {
+	switch {
+	case path == "":
+		return errors.New("logging: log file path is empty")
+	case isDir(path):
+		return fmt.Errorf("logging: log file path %s is a directory", path)
+	case o.bufferSize < 0:
+		return fmt.Errorf("logging: invalid buffer size %d", o.bufferSize)
+	case o.syncEvery < 0:
+		return fmt.Errorf("logging: invalid sync every %d bytes", o.syncEvery)
+	case o.maxTotalSize < 0:
+		return fmt.Errorf("logging: invalid max total size %d", o.maxTotalSize)
+	case o.maxAge < 0:
+		return fmt.Errorf("logging: invalid max age %s", o.maxAge)
+	case o.keepUncompressed < 0:
+		return fmt.Errorf("logging: invalid keep uncompressed %d", o.keepUncompressed)
+	case o.maxLines < 0:
+		return fmt.Errorf("logging: invalid max lines %d", o.maxLines)
+	case o.rotateEvery < 0:
+		return fmt.Errorf("logging: invalid rotate interval %s", o.rotateEvery)
+	case o.lockPolicy != 0 && hasPathPlaceholders(path):
+		return errors.New("logging: WithFileLock() conflicts with date placeholders of log file path")
+	case o.lockPolicy != 0 && o.lockPolicy != LockFail && o.lockPolicy != LockFallbackPID:
+		return fmt.Errorf("logging: invalid lock policy %d", o.lockPolicy)
+	case o.lowDiskAction != 0 && (o.lowDiskAction < LowDiskPrune || o.lowDiskAction > LowDiskDrop):
+		return fmt.Errorf("logging: invalid low disk action %s", o.lowDiskAction)
+	case o.lowDiskAction != 0 && o.lowDiskFree <= 0:
+		return fmt.Errorf("logging: low disk action %s without min free space", o.lowDiskAction)
+	case o.backupName != nil && (o.backupUTC || o.backupHost || o.backupPID):
+		return errors.New("logging: backup name func conflicts with WithBackupUTC() and WithBackupOrigin()")
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// WithoutRegistry prevents the writer registered to package writer registry,
+// so it is not included in package level statistics, health, and not closed
+// by CloseAll(). For users manage writer life cycle themselves.
+func WithoutRegistry() Option {
+	return func(o *writerOptions) {
+		o.noRegistry = true
+	}
+}
+
+// WithName set ID of the writer, used in internal error reports, metrics,
+// health problems and events. Writers without name get an auto generated ID,
+// derived from writer type and target path, such as "file:/var/log/app.log",
+// or writer type and a sequence number if no target path, such as
+// "failover-1".
+func WithName(name string) Option {
+	return func(o *writerOptions) {
+		o.name = name
+	}
+}
+
+// WithRotateEvery makes file log writer rotate log file if it is written
+// for d, even if not reach max length, whichever comes first. The interval
+// starts from the creation of the writer, and restarts on each rotation.
+// Zero, the default, rotates by size only.
+func WithRotateEvery(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.rotateEvery = d
+	}
+}
+
+// WithFreshStart makes NewFileLogWriter rotate existing log file on
+// creation, so each process run gets its own log file. Existing log file is
+// backed up and compressed like normal rotation, no-op if it is empty.
+func WithFreshStart() Option {
+	return func(o *writerOptions) {
+		o.freshStart = true
+	}
+}
+
+// WithFailoverThreshold set how long primary writer of FailoverWriter keeps
+// failing before switch to secondary writer, default 1 minute. Zero means
+// switch on first failure.
+func WithFailoverThreshold(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.failoverThreshold = d
+	}
+}
+
+// WithFailoverProbeInterval set how often FailoverWriter probes primary
+// writer in secondary mode, default 10 seconds.
+func WithFailoverProbeInterval(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.probeInterval = d
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// Returns uid and gid of file, false if not supported.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,10 @@
+package logging
+
+import (
+	"os"
+)
+
+// File owner not supported on windows.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// Date placeholders of log file path, such as `/var/log/app/%Y-%m-%d/app.log`,
+// `%%` for a literal percent sign. Path without placeholders used as is,
+// percent signs included.
+var pathPlaceholders = map[byte]string{
+	'Y': "2006",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+}
+
+// Returns true if path contains date placeholders.
+func hasPathPlaceholders(path string) bool {
+	for i := 0; i < len(path)-1; i++ {
+		if path[i] != '%' {
+			continue
+		}
+		if _, ok := pathPlaceholders[path[i+1]]; ok {
+			return true
+		}
+		if path[i+1] == '%' {
+			i++
+		}
+	}
+	return false
+}
+
+// Expand placeholders of path template by f, `%%` to `%`, unknown ones kept
+// as is.
+func expandPathTemplate(tmpl string, f func(layout string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		if c != '%' || i == len(tmpl)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		if layout, ok := pathPlaceholders[tmpl[i+1]]; ok {
+			b.WriteString(f(layout))
+			i++
+		} else if tmpl[i+1] == '%' {
+			b.WriteByte('%')
+			i++
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Returns log file path of template at t.
+func resolvePathTemplate(tmpl string, t time.Time) string {
+	return expandPathTemplate(tmpl, t.Format)
+}
+
+// Returns error if placeholders in file name of template, only directories
+// can be dated.
+func validatePathTemplate(tmpl string) error {
+	if hasPathPlaceholders(filepath.Base(tmpl)) {
+		return fmt.Errorf("logging: date placeholders of log file path %s must be in directory", tmpl)
+	}
+	return nil
+}
+
+// Returns start of next hour after t, the earliest the resolved path may
+// change.
+func nextPathCheck(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+}
+
+// Switch to the log file of resolved path if date changed, the previous log
+// file backed up and compressed as rotation. Must hold w.l.
+func (w *fileLogWriter) switchDatedPathLocked() error {
+	now := hal.Now()
+	if now.Before(w.pathCheckAt) {
+		return nil
+	}
+	w.pathCheckAt = nextPathCheck(now)
+	next := resolvePathTemplate(w.pathTemplate, now)
+	if next == w.path {
+		return nil
+	}
+
+	if debugEnabled() {
+		debugf("rotate: %s date changed, switch to %s", w.path, next)
+	}
+	size := atomic.LoadInt64(&w.currentSize)
+	if size <= w.headerLen {
+		// nothing to back up
+		old := w.f.Name()
+		f, err := w.openFile(next)
+		if err != nil {
+			return err
+		}
+		if err = w.flushLocked(); err != nil {
+			w.reportError("file", err)
+		}
+		safeClose("file", w.f)
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			w.reportError("file", err)
+		}
+		w.f = f
+		w.setPath(next)
+		if info, err := f.Stat(); err == nil {
+			atomic.StoreInt64(&w.currentSize, info.Size())
+		}
+		w.resetLineCount()
+		w.openedAt = now
+		w.writeHeader()
+		return nil
+	}
+
+	if err := w.rotateToLocked(now, w.newBackupFilename(w.f.Name()), size, next); err != nil {
+		return err
+	}
+	w.setPath(next)
+	return nil
+}
+
+// Update the live log file path. Must hold w.l.
+func (w *fileLogWriter) setPath(path string) {
+	w.pathL.Lock()
+	w.path = path
+	w.pathL.Unlock()
+}
+
+// Returns live log file paths of all dated directories of the template,
+// the current one first. Returns only the current path if not dated.
+func (w *fileLogWriter) datedPaths() []string {
+	current := w.CurrentPath()
+	if w.pathTemplate == "" || w.archiveDir != "" {
+		// backups of all directories in the archive directory
+		return []string{current}
+	}
+
+	// placeholders not changed by escaping, no glob meta characters in them
+	pattern := expandPathTemplate(globEscape(filepath.Dir(w.pathTemplate)), func(string) string { return "*" })
+	dirs, err := filepath.Glob(pattern)
+	if err != nil {
+		w.reportError("retention", err)
+		return []string{current}
+	}
+
+	r := []string{current}
+	base := filepath.Base(w.pathTemplate)
+	for _, d := range dirs {
+		if p := filepath.Join(d, base); p != current && isDir(d) {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// Remove empty dated directories except the current one.
+func (w *fileLogWriter) removeEmptyDatedDirs() {
+	paths := w.datedPaths()
+	for _, p := range paths[1:] {
+		// fails if not empty
+		_ = os.Remove(filepath.Dir(p))
+	}
+}
+
+// Returns log file path in the first dated directory of the template, the
+// non dated parent, such as `/var/log/app/app.log` of
+// `/var/log/app/%Y-%m-%d/app.log`. Returns the log file path if not dated.
+func (w *fileLogWriter) linkBase() string {
+	if w.pathTemplate == "" {
+		return w.CurrentPath()
+	}
+	dir := filepath.Dir(w.pathTemplate)
+	for hasPathPlaceholders(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return filepath.Join(dir, filepath.Base(w.pathTemplate))
+}
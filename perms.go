@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+)
+
+// WithFileMode set permission bits of created log files and archives, default
+// to 0640, applied on initial open and after rotation, existing files not
+// changed unless WithStrictPerms(). File log writer reports a Degraded health
+// problem and an internal error if permission of the live log file drift
+// from mode, checked on open and periodically on write, not checked if mode
+// not configured.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *writerOptions) {
+		o.fileMode = mode.Perm()
+	}
+}
+
+// WithFileOwner set owner of created log files, -1 to not change uid or gid.
+// Drift of owner reported like WithFileMode().
+func WithFileOwner(uid, gid int) Option {
+	return func(o *writerOptions) {
+		o.uid, o.gid = uid, gid
+	}
+}
+
+// WithStrictPerms corrects permission and owner of the live log file by
+// Chmod, Chown if drift from WithFileMode(), WithFileOwner().
+func WithStrictPerms() Option {
+	return func(o *writerOptions) {
+		o.strictPerms = true
+	}
+}
+
+// Returns permission of created files.
+func (w *fileLogWriter) createMode() os.FileMode {
+	if w.fileMode == 0 {
+		return defaultFileMode
+	}
+	return w.fileMode
+}
+
+// Open the live log file, set owner if created.
+func (w *fileLogWriter) openFile(path string) (logFile, error) {
+	_, statErr := os.Stat(path)
+	f, err := openLogFileFunc(path, w.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if os.IsNotExist(statErr) && (w.uid >= 0 || w.gid >= 0) {
+		if err := os.Chown(path, w.uid, w.gid); err != nil {
+			w.reportError("file", err)
+		}
+	}
+	w.updateCurrentLink(path)
+	return f, nil
+}
+
+// Compare mode and owner of the live log file with configured, info is stat
+// result of the live log file. Warn once when drift.
+func (w *fileLogWriter) checkPerms(info os.FileInfo) {
+	drift := w.permsDrift(info)
+	if drift == "" {
+		w.permDrift.clear()
+		return
+	}
+
+	if w.strictPerms {
+		err := w.fixPerms()
+		if err == nil {
+			w.permDrift.clear()
+			return
+		}
+		drift += fmt.Sprintf(", correct failed: %s", err)
+	}
+
+	if since, _ := w.permDrift.get(); since.IsZero() {
+		err := fmt.Errorf("permission of %s drift: %s", w.CurrentPath(), drift)
+		w.permDrift.set(err)
+		w.reportError("file", err)
+	}
+}
+
+// Returns description of drift, empty if matches.
+func (w *fileLogWriter) permsDrift(info os.FileInfo) string {
+	var r string
+	if w.fileMode != 0 && info.Mode().Perm() != w.fileMode {
+		r = fmt.Sprintf("mode %s, want %s", info.Mode().Perm(), w.fileMode)
+	}
+	if w.uid >= 0 || w.gid >= 0 {
+		if uid, gid, ok := fileOwner(info); ok && (w.uid >= 0 && uid != w.uid || w.gid >= 0 && gid != w.gid) {
+			if r != "" {
+				r += ", "
+			}
+			r += fmt.Sprintf("owner %d:%d, want %d:%d", uid, gid, w.uid, w.gid)
+		}
+	}
+	return r
+}
+
+func (w *fileLogWriter) fixPerms() error {
+	if w.fileMode != 0 {
+		if err := os.Chmod(w.CurrentPath(), w.fileMode); err != nil {
+			return err
+		}
+	}
+	if w.uid >= 0 || w.gid >= 0 {
+		return os.Chown(w.CurrentPath(), w.uid, w.gid)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// PurgeOlderThan deletes archives rotated before t, compressed or not, never
+// the live log file. Run by the background worker after queued compressions,
+// never deletes a backup being compressed. Returns number of deleted files.
+func (w *fileLogWriter) PurgeOlderThan(t time.Time) (deleted int, err error) {
+	return w.purgeByWorker(func(a ArchiveInfo) bool {
+		return a.RotatedAt.Before(t)
+	})
+}
+
+// Purge is PurgeOlderThan, such as wipe historical logs before shipping a
+// support bundle.
+func (w *fileLogWriter) Purge(olderThan time.Time) (int, error) {
+	return w.PurgeOlderThan(olderThan)
+}
+
+// Purge deletes backups of the alive file log writer of path rotated before
+// olderThan, see PurgeOlderThan method of file log writer. Returns
+// ErrNoFileLogWriter if no such writer, backups named and retained by its
+// options.
+func Purge(path string, olderThan time.Time) (int, error) {
+	w, err := registeredFileWriter(path)
+	if err != nil {
+		return 0, err
+	}
+	return w.PurgeOlderThan(olderThan)
+}
+
+// PurgeAll deletes all archives, and truncates the live log file. Returns
+// number of deleted archives.
+func (w *fileLogWriter) PurgeAll() (deleted int, err error) {
+	if deleted, err = w.purgeByWorker(func(ArchiveInfo) bool { return true }); err != nil {
+		return
+	}
+
+	w.l.Lock()
+	defer w.l.Unlock()
+	if w.closed {
+		return deleted, ErrWriterClosed
+	}
+	if w.f == nil && !w.lazyOpen {
+		return deleted, errLogFileNotOpen
+	}
+
+	var size int64
+	if w.buf != nil {
+		// buffered records purged as well
+		w.buf.Reset(bufferTarget{w})
+	}
+	if w.f == nil {
+		// handle closed by handle cache, truncate by path
+		if info, err := os.Stat(w.CurrentPath()); err == nil {
+			size = info.Size()
+		}
+		if err = os.Truncate(w.CurrentPath(), 0); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		err = nil
+	} else {
+		if info, err := w.f.Stat(); err == nil {
+			size = info.Size()
+		}
+		if err = w.f.Truncate(0); err != nil {
+			return
+		}
+	}
+	atomic.StoreInt64(&w.currentSize, 0)
+	w.lineCount, w.headerLen = 0, 0
+	w.audit.record(auditEntry{Action: auditDelete, File: w.CurrentPath(), Reason: reasonPurge, SizeBefore: size})
+	// same as rotation, written on open if handle closed by handle cache
+	w.writeHeader()
+	return
+}
+
+// Run purge() by the background worker, wait it done.
+func (w *fileLogWriter) purgeByWorker(match func(ArchiveInfo) bool) (deleted int, err error) {
+	w.runByWorker(func() {
+		deleted, err = w.purge(match)
+	})
+	return
+}
+
+func (w *fileLogWriter) purge(match func(ArchiveInfo) bool) (deleted int, err error) {
+	archives, err := w.listArchives()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, a := range archives {
+		if !match(a) {
+			continue
+		}
+
+		if !a.Compressed {
+			w.markPurged(a.Path)
+		}
+
+		if e := w.removeArchive(a.Path); e != nil {
+			if !os.IsNotExist(e) && err == nil {
+				err = e
+			}
+			continue
+		}
+		removeSidecars(a.Path)
+		deleted++
+		w.audit.record(auditEntry{Action: auditDelete, File: a.Path, Reason: reasonPurge, SizeBefore: a.Size})
+		if debugEnabled() {
+			debugf("purge: deleted %s", a.Path)
+		}
+	}
+	if deleted > 0 {
+		w.reconcileManifest()
+	}
+	return
+}
+
+// Record backup being compressed, see markPurged().
+func (w *fileLogWriter) startCompress(bakFile string) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.purged == nil {
+		w.purged = map[string]bool{}
+	}
+	w.purged[bakFile] = false
+}
+
+// Mark uncompressed backup deleted, compression output of it removed if
+// being compressed. No-op if not being compressed, a later backup of the same
+// name, such as numbered, not affected.
+func (w *fileLogWriter) markPurged(bakFile string) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if _, ok := w.purged[bakFile]; ok {
+		w.purged[bakFile] = true
+	}
+}
+
+// Returns true if the backup purged while compressed, and forget it.
+func (w *fileLogWriter) takePurged(bakFile string) bool {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	purged := w.purged[bakFile]
+	delete(w.purged, bakFile)
+	return purged
+}
+
+func (w *fileLogWriter) removePurged(f string) {
+	if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+		w.reportError("purge", err)
+	}
+}
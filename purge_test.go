@@ -0,0 +1,174 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Purged backup name reused by numbered backups, the new backup compressed,
+// not removed as the purged one.
+func TestPurgeNumberedNameReused(t *testing.T) {
+	w := newTestFileWriter(t, 0, 0, WithNumberedBackups(), WithCompressor(&flakyCompressor{fails: 1}))
+	path := w.CurrentPath()
+	rotateTimes(t, w, 1)
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("backup left uncompressed: %v", err)
+	}
+	if _, err := w.PurgeAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("important line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.waitBackground()
+	if content := readArchive(t, path+".1.gz"); content != "important line\n" {
+		t.Errorf("archive content %q", content)
+	}
+}
+
+func TestPurgeOlderThan(t *testing.T) {
+	tests := []struct {
+		name      string
+		olderThan time.Duration // relative to now
+		want      int
+	}{
+		{"all", time.Minute, 3},
+		{"none", -time.Hour, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestFileWriter(t, 0, 0)
+			rotateTimes(t, w, 3)
+			if _, err := w.Write([]byte("live\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := w.Purge(time.Now().Add(tt.olderThan))
+			if err != nil {
+				t.Fatal(err)
+			}
+			left, err := w.listArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != tt.want || len(left) != 3-tt.want {
+				t.Errorf("deleted %d, left %d, want deleted %d", deleted, len(left), tt.want)
+			}
+			// live log file never purged
+			if content, err := ioutil.ReadFile(w.CurrentPath()); err != nil || string(content) != "live\n" {
+				t.Errorf("live log file %q, %v", content, err)
+			}
+		})
+	}
+}
+
+// Package level operations changing backups require alive writer of path,
+// not an ad-hoc writer of default options.
+func TestPurgeUnregistered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w := openTestFileWriter(t, path, 0, 0)
+	rotateTimes(t, w, 1)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Purge(path, time.Now().Add(time.Minute)); !errors.Is(err, ErrNoFileLogWriter) {
+		t.Errorf("Purge: %v, want ErrNoFileLogWriter", err)
+	}
+	if err := CompressBackups(path); !errors.Is(err, ErrNoFileLogWriter) {
+		t.Errorf("CompressBackups: %v, want ErrNoFileLogWriter", err)
+	}
+	if archives, err := ListArchives(path); err != nil || len(archives) != 1 {
+		t.Errorf("archives %v, %v, want kept", archives, err)
+	}
+}
+
+// OnRotate callback purges and compresses backups of its writer, run
+// directly, not deadlocked waiting for the worker running the callback.
+func TestPurgeFromOnRotate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"timestamp", nil},
+		{"numbered", []Option{WithNumberedBackups()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w *fileLogWriter
+			done := make(chan error, 1)
+			onRotate := WithOnRotate(func(backup, archive string) {
+				if _, err := w.PurgeOlderThan(time.Now().Add(time.Hour)); err != nil {
+					done <- err
+					return
+				}
+				done <- w.CompressBackups()
+			})
+			w = newTestFileWriter(t, 0, 0, append(tt.opts, onRotate)...)
+			if _, err := w.Write([]byte("line\n")); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("deadlocked")
+			}
+			w.waitBackground()
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archives) != 0 {
+				t.Errorf("archives %v not purged", archives)
+			}
+		})
+	}
+}
+
+// PurgeAll truncates the live log file, header rewritten and lines counted
+// from zero, same as a new log file.
+func TestPurgeAll(t *testing.T) {
+	header := WithHeader(func() []byte { return []byte("# header\n") })
+	w := newTestFileWriter(t, 0, 0, header, WithMaxLines(3))
+	rotateTimes(t, w, 1)
+	for _, s := range []string{"a\n", "b\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if deleted, err := w.PurgeAll(); err != nil || deleted != 1 {
+		t.Fatalf("purge all: %d, %v", deleted, err)
+	}
+	live, err := ioutil.ReadFile(w.CurrentPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(live) != "# header\n" {
+		t.Errorf("live log file %q after purged", live)
+	}
+
+	for _, s := range []string{"c\n", "d\n", "e\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := archiveContents(t, w), []string{"# header\nc\nd\ne\n"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("archives %q, want %q", got, want)
+	}
+}
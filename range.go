@@ -0,0 +1,222 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ReadRange returns a reader of logs written between from and to, from
+// archives and the live log file, in chronological order. Files selected by
+// rotation time, if filterLines is true, lines also filtered by their
+// timestamps, lines without timestamp follow the decision of previous line.
+//
+// Archives deleted by retention before read are skipped, encrypted archives
+// decrypted by the key of the writer.
+func (w *fileLogWriter) ReadRange(from, to time.Time, filterLines bool) (io.ReadCloser, error) {
+	return ReadRange(w.CurrentPath(), from, to, filterLines)
+}
+
+// ReadRange returns a reader of logs written between from and to, of log file
+// at path, see ReadRange method of file log writer.
+func ReadRange(path string, from, to time.Time, filterLines bool) (io.ReadCloser, error) {
+	files, err := rangeFiles(path, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.ReadCloser = &multiFileReader{files: files, key: lookupFileWriter(path).key}
+	if filterLines {
+		r = newLineTimeFilter(r, from, to)
+	}
+	return r, nil
+}
+
+// OpenLogs returns a reader of logs written since, from archives and the live
+// log file, such as scan logs of last 24 hours, see OpenLogs().
+func (w *fileLogWriter) OpenLogs(since time.Time) (io.ReadCloser, error) {
+	return OpenLogs(w.CurrentPath(), since)
+}
+
+// OpenLogs returns a reader concatenates archives of log file at path, and
+// the live log file last, oldest first, decompressed and decrypted. Archives
+// rotated before since skipped, selected by rotation time in file name, logs
+// of the oldest selected archive may written before since. Unreadable archives,
+// such as corrupt, skipped and reported as internal error, the rest still
+// read.
+func OpenLogs(path string, since time.Time) (io.ReadCloser, error) {
+	files, err := rangeFiles(path, since, endOfTime)
+	if err != nil {
+		return nil, err
+	}
+	return &multiFileReader{files: files, key: lookupFileWriter(path).key, live: path, skipCorrupt: true}, nil
+}
+
+// far future, no upper bound of time range
+var endOfTime = time.Unix(1<<62, 0)
+
+// Returns archives and the live log file may contain logs written between
+// from and to, in chronological order.
+func rangeFiles(path string, from, to time.Time) ([]string, error) {
+	archives, err := ListArchives(path)
+	if err != nil {
+		return nil, err
+	}
+	// oldest first
+	for i, j := 0, len(archives)-1; i < j; i, j = i+1, j-1 {
+		archives[i], archives[j] = archives[j], archives[i]
+	}
+
+	// archive contains logs written after previous rotation, until its own
+	// rotation.
+	var files []string
+	var prev time.Time
+	for _, a := range archives {
+		if !a.RotatedAt.Before(from) && !prev.After(to) {
+			files = append(files, a.Path)
+		}
+		prev = a.RotatedAt
+	}
+	if !prev.After(to) {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// Concatenate files, open file lazily, skip files not exist.
+type multiFileReader struct {
+	files []string
+	key   KeyFunc
+	cur   io.ReadCloser
+
+	// skip archives fail to open or read, except the live log file
+	skipCorrupt bool
+	live        string
+	curPath     string
+}
+
+func (r *multiFileReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.files) == 0 {
+				return 0, io.EOF
+			}
+			f, err := OpenArchiveKey(r.files[0], r.key)
+			r.curPath, r.files = r.files[0], r.files[1:]
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				if r.skipArchive(err) {
+					continue
+				}
+				return 0, err
+			}
+			r.cur = f
+		}
+
+		n, err := r.cur.Read(p)
+		if err == io.EOF {
+			err = r.cur.Close()
+			r.cur = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		if err != nil && r.skipArchive(err) {
+			safeClose("archive", r.cur)
+			r.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Returns true if error of current file skipped, reported as internal error.
+func (r *multiFileReader) skipArchive(err error) bool {
+	if !r.skipCorrupt || r.curPath == r.live {
+		return false
+	}
+	reportError("archive", fmt.Errorf("skip unreadable archive %s: %w", r.curPath, err))
+	return true
+}
+
+func (r *multiFileReader) Close() error {
+	r.files = nil
+	if r.cur != nil {
+		err := r.cur.Close()
+		r.cur = nil
+		return err
+	}
+	return nil
+}
+
+// layout of timestamp prefix written by standard log package
+const logLineTimeLayout = `2006/01/02 15:04:05`
+
+type lineTimeFilter struct {
+	src      io.ReadCloser
+	br       *bufio.Reader
+	from, to time.Time
+	keep     bool   // decision of last line with timestamp
+	buf      []byte // pending output
+	err      error
+}
+
+func newLineTimeFilter(src io.ReadCloser, from, to time.Time) *lineTimeFilter {
+	return &lineTimeFilter{src: src, br: bufio.NewReader(src), from: from, to: to}
+}
+
+func (f *lineTimeFilter) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+
+		var line []byte
+		line, f.err = f.br.ReadBytes('\n')
+		if len(line) == 0 {
+			continue
+		}
+		if t, ok := parseLineTime(line); ok {
+			f.keep = !t.Before(f.from) && !t.After(f.to)
+		}
+		if f.keep {
+			f.buf = line
+		}
+	}
+
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+func (f *lineTimeFilter) Close() error {
+	return f.src.Close()
+}
+
+// Parse timestamp prefix of log line in local time, optional with
+// microseconds.
+func parseLineTime(line []byte) (time.Time, bool) {
+	if len(line) < len(logLineTimeLayout) {
+		return time.Time{}, false
+	}
+	end := len(logLineTimeLayout)
+	if end < len(line) && line[end] == '.' {
+		if i := bytes.IndexByte(line[end:], ' '); i > 0 {
+			end += i
+		}
+	}
+	t, err := time.ParseInLocation(logLineTimeLayout, string(line[:end]), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
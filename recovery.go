@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// Backoff of reopening the log file after write error.
+const (
+	minRecoverBackoff = 100 * time.Millisecond
+	maxRecoverBackoff = 30 * time.Second
+)
+
+// Close the live log file after write error, and reopen it in background.
+// Records written meanwhile dropped, see write(). Must hold w.l.
+func (w *fileLogWriter) enterRecoveryLocked(err error) {
+	if w.recoverStop != nil {
+		return
+	}
+
+	w.reportError("file", fmt.Errorf("write %s, reopen in background: %w", w.CurrentPath(), err))
+	if w.buf != nil {
+		w.resetBuffer(err)
+	}
+	if w.f != nil {
+		// may closed already, such as failed rotation
+		_ = w.f.Close()
+		w.f = nil
+	}
+	if w.lazyOpen {
+		handleClosed(w)
+	}
+	w.recoverStop = make(chan struct{})
+	go w.runRecovery(w.recoverStop)
+}
+
+// Reopen the live log file with exponential backoff until succeed, or stop
+// closed.
+func (w *fileLogWriter) runRecovery(stop chan struct{}) {
+	d := minRecoverBackoff
+	for {
+		t := time.NewTimer(d)
+		select {
+		case <-stop:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		if w.tryRecover() {
+			return
+		}
+		if d *= 2; d > maxRecoverBackoff {
+			d = maxRecoverBackoff
+		}
+	}
+}
+
+// Returns true if the live log file reopened, or the writer closed.
+func (w *fileLogWriter) tryRecover() bool {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed {
+		return true
+	}
+	f, err := w.openFile(w.CurrentPath())
+	if err != nil {
+		if debugEnabled() {
+			debugf("recover: reopen %s failed: %v", w.CurrentPath(), err)
+		}
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		safeClose("file", f)
+		return false
+	}
+
+	if debugEnabled() {
+		debugf("recover: reopened %s", w.CurrentPath())
+	}
+	atomic.StoreInt64(&w.currentSize, info.Size())
+	w.writesSinceStat, w.lastStat = 0, hal.Now()
+	w.resetLineCount()
+	w.recoverStop = nil
+	w.failure.clear()
+	if w.lazyOpen {
+		// opened on next write
+		safeClose("file", f)
+		return true
+	}
+	w.f = f
+	w.writeHeader()
+	return true
+}
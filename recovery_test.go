@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Rename of rotation failed, records kept writing to the oversized log file,
+// not dropped by recovery, rotated after rotateRetryInterval.
+func TestRotateRenameFailed(t *testing.T) {
+	now := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+	freezeNow(t, now)
+	failRename(t, syscall.EACCES)
+	w := newTestFileWriter(t, 10, 0)
+	for i := 0; i < 3; i++ {
+		if n, err := w.Write([]byte("123456\n")); err != nil || n != 7 {
+			t.Fatalf("write: %d, %v", n, err)
+		}
+	}
+	w.l.Lock()
+	recovering := w.recoverStop != nil
+	w.l.Unlock()
+	if recovering {
+		t.Error("entered recovery")
+	}
+	if s, _ := w.Stats(); s.Dropped != 0 {
+		t.Errorf("dropped %d", s.Dropped)
+	}
+	live, err := ioutil.ReadFile(w.CurrentPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(live) != "123456\n123456\n123456\n" {
+		t.Errorf("live log file %q", live)
+	}
+
+	renameFunc = os.Rename
+	freezeNow(t, now.Add(rotateRetryInterval))
+	if _, err := w.Write([]byte("x\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := archiveContents(t, w); len(got) != 1 || got[0] != string(live) {
+		t.Errorf("archives %q, want %q", got, live)
+	}
+}
+
+// Failed to open the new log file after the record written and the log file
+// renamed, enters recovery, the record not counted as dropped.
+func TestRotateAfterWriteFailed(t *testing.T) {
+	old := openLogFileFunc
+	// restored after the writer closed, not read by recovery any more
+	t.Cleanup(func() { openLogFileFunc = old })
+	w := newTestFileWriter(t, 10, 0)
+	openLogFileFunc = func(path string, perm os.FileMode) (logFile, error) {
+		return nil, &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+	}
+
+	if n, err := w.Write([]byte("1234567890")); err != nil || n != 10 {
+		t.Fatalf("write: %d, %v", n, err)
+	}
+	w.l.Lock()
+	recovering := w.recoverStop != nil
+	w.l.Unlock()
+	if !recovering {
+		t.Error("not entered recovery")
+	}
+	if s, _ := w.Stats(); s.Dropped != 0 {
+		t.Errorf("dropped %d, written record counted", s.Dropped)
+	}
+	archives, err := w.ListArchives()
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("archives %v, %v", archives, err)
+	}
+	if content, err := ioutil.ReadFile(archives[0].Path); err != nil || string(content) != "1234567890" {
+		t.Errorf("backup %q, %v", content, err)
+	}
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redforks/hal"
+	"github.com/redforks/testing/reset"
+)
+
+// Writers created by this package register themselves, so their statistics,
+// health can be reported at package level, and closed by CloseAll().
+type registeredWriter interface {
+	stats() WriterStats
+	counters() *writerCounters
+	Close() error
+}
+
+type registryEntry struct {
+	w       registeredWriter
+	created time.Time
+}
+
+var (
+	registryLock sync.Mutex
+	registry     []registryEntry // ordered by creation
+)
+
+// WriterInfo describes a writer in package writer registry.
+type WriterInfo struct {
+	ID        string // writer ID, see WithName()
+	Kind      string // writer type: "file", "async", "failover", "spool"
+	Path      string // log file path or spool directory, empty if not apply
+	CreatedAt time.Time
+	Stats     WriterStats
+}
+
+func registerWriter(w registeredWriter, o *writerOptions) {
+	if o.noRegistry {
+		return
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry = append(registry, registryEntry{w, hal.Now()})
+}
+
+func unregisterWriter(w registeredWriter) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	for i, item := range registry {
+		if item.w == w {
+			registry = append(registry[:i], registry[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns copy of registry.
+func registeredWriters() []registeredWriter {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	r := make([]registeredWriter, len(registry))
+	for i, item := range registry {
+		r[i] = item.w
+	}
+	return r
+}
+
+// Writers returns all alive writers created by this package, except writers
+// created with WithoutRegistry option. Useful to assert writers not leaked in
+// tests.
+func Writers() []WriterInfo {
+	registryLock.Lock()
+	entries := make([]registryEntry, len(registry))
+	copy(entries, registry)
+	registryLock.Unlock()
+
+	r := make([]WriterInfo, len(entries))
+	for i, item := range entries {
+		s := item.w.stats()
+		r[i] = WriterInfo{s.ID, s.Kind, s.Path, item.created, s}
+	}
+	return r
+}
+
+// CloseAll closes all alive writers in registry, later created closed first,
+// so wrapper writers flushed to inner writers before they closed. Returns the
+// first error, or ctx.Err() if ctx done before all writers closed.
+//
+// CloseAll called automatically after each test in test mode (see
+// github.com/redforks/testing/reset).
+func CloseAll(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		writers := registeredWriters()
+		var err error
+		for i := len(writers) - 1; i >= 0; i-- {
+			if e := writers[i].Close(); err == nil {
+				err = e
+			}
+			// writers not unregister themselves on Close failure
+			unregisterWriter(writers[i])
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func init() {
+	reset.Register(func() {
+		if err := CloseAll(context.Background()); err != nil {
+			reportError("registry", err)
+		}
+
+		registryLock.Lock()
+		defaultFileWriter, defaultAsyncWriter = nil, nil
+		currentOption, currentLogFile = nil, ""
+		stopStatusLocked()
+		registryLock.Unlock()
+	}, nil)
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/redforks/hal"
+)
+
+// Reopen closes the live log file and opens the log file path again, created
+// if not exist, without rotation of own, for external rotation such as
+// logrotate renamed the log file. No-op if the open file is still the file
+// of the path. Safe to call concurrently with Write.
+func (w *fileLogWriter) Reopen() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	switch {
+	case w.closed:
+		return ErrWriterClosed
+	case w.frozen != nil:
+		return errWriterFrozen
+	case w.f == nil:
+		// lazy open handle closed, opens the path on next write
+		return nil
+	}
+
+	info, err := w.f.Stat()
+	if err != nil {
+		return err
+	}
+	if pathInfo, err := os.Stat(w.CurrentPath()); err == nil && os.SameFile(info, pathInfo) {
+		return nil
+	}
+
+	if debugEnabled() {
+		debugf("reopen: %s", w.CurrentPath())
+	}
+	if err = w.flushLocked(); err != nil {
+		w.reportError("file", err)
+	}
+	safeClose("file", w.f)
+	if w.f, err = w.openFile(w.CurrentPath()); err != nil {
+		w.failure.set(err)
+		return err
+	}
+	if info, err = w.f.Stat(); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&w.currentSize, info.Size())
+	w.writesSinceStat, w.lastStat = 0, hal.Now()
+	w.resetLineCount()
+	w.writeHeader()
+	return nil
+}
+
+// Reopen reopens the log file created by config, or the first alive file log
+// writer, see Reopen method of file log writer.
+func Reopen() error {
+	w, _ := defaultWriters()
+	if w == nil {
+		return ErrNoFileLogWriter
+	}
+	return w.Reopen()
+}
+
+// HandleReopenSignal reopens all alive file log writers when receive one of
+// the signals, SIGHUP if no signal specified, such as sent by postrotate
+// script of logrotate. Call the returned function to stop handling.
+func HandleReopenSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				reopenAll()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+func reopenAll() {
+	for _, w := range registeredWriters() {
+		if fw, ok := w.(*fileLogWriter); ok {
+			if err := fw.Reopen(); err != nil && err != ErrWriterClosed {
+				fw.reportError("file", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// WithMaxTotalSize limits total size of backups, compressed or not, oldest
+// backups deleted until total size not greater than maxSize, after each
+// compression. Works together with maxFiles, whichever is stricter wins. The
+// live log file and the newest backup never deleted, even if the backup alone
+// larger than maxSize. Zero, the default, not limited.
+func WithMaxTotalSize(maxSize int64) Option {
+	return func(o *writerOptions) {
+		o.maxTotalSize = maxSize
+	}
+}
+
+// WithMaxAge deletes backups rotated before maxAge ago, compressed or not,
+// after each compression and once on creation. Rotation time parsed from
+// backup file name, fallback to modification time. Works together with
+// maxFiles and WithMaxTotalSize(). Zero, the default, not limited.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(o *writerOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// Delete backups older than maxAge.
+func (w *fileLogWriter) cleanByAge() error {
+	archives, err := w.listArchives()
+	if err != nil {
+		return err
+	}
+
+	deadline := hal.Now().Add(-w.maxAge)
+	for _, a := range archives {
+		if !a.RotatedAt.Before(deadline) {
+			continue
+		}
+		if !a.Compressed {
+			w.markPurged(a.Path)
+		}
+		if err := w.deleteBackup(a.Path, reasonAge, a.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete oldest backups until total size not greater than maxTotalSize.
+func (w *fileLogWriter) cleanBySize() error {
+	// newest first
+	archives, err := w.listArchives()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, a := range archives {
+		total += a.Size
+	}
+	if debugEnabled() {
+		debugf("retention: backups of %s total %d bytes, maxTotalSize %d", w.CurrentPath(), total, w.maxTotalSize)
+	}
+
+	for i := len(archives) - 1; i > 0 && total > w.maxTotalSize; i-- {
+		a := archives[i]
+		if !a.Compressed {
+			w.markPurged(a.Path)
+		}
+		if err := w.deleteBackup(a.Path, reasonSize, a.Size); err != nil {
+			return err
+		}
+		total -= a.Size
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// Plain backups pruned by retention while compression failing, the live log
+// file untouched.
+func TestRetentionCompressFailing(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxFiles int
+		opts     []Option
+	}{
+		{"max files", 2, nil},
+		{"max total size", 0, []Option{WithMaxTotalSize(14)}},
+		{"max age", 0, []Option{WithMaxAge(90 * time.Minute)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCompressRetry(t, time.Hour, 10)
+			c := &flakyCompressor{fails: 1 << 30}
+			w := newTestFileWriter(t, 0, tt.maxFiles, append(tt.opts, WithCompressor(c))...)
+			base := time.Date(2024, 5, 1, 15, 4, 5, 0, time.Local)
+			for i := 0; i < 5; i++ {
+				freezeNow(t, base.Add(time.Duration(i)*time.Hour))
+				if _, err := fmt.Fprintf(w, "line %d\n", i); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+				w.waitBackground()
+			}
+			if _, err := w.Write([]byte("live\n")); err != nil {
+				t.Fatal(err)
+			}
+			w.waitBackground()
+			if len(c.attempts()) == 0 {
+				t.Fatal("compression not attempted")
+			}
+
+			archives, err := w.ListArchives()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, a := range archives {
+				if a.Compressed {
+					t.Errorf("%s compressed", a.Path)
+				}
+				content, err := ioutil.ReadFile(a.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(content))
+			}
+			if want := []string{"line 4\n", "line 3\n"}; fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("backups %q, want %q", got, want)
+			}
+			if live, err := ioutil.ReadFile(w.CurrentPath()); err != nil || string(live) != "live\n" {
+				t.Errorf("live log file %q, %v", live, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+var errWriterFrozen = errors.New("logging: writer frozen")
+
+// WithMinRotateInterval makes file log writer not rotate by size sooner
+// than d since last rotation, keeps writing to the live log file, beyond
+// maxLen, until d passed, guards against rotation storm of a too small
+// maxLen. Reports an internal error once when starts suppressing. Rotate()
+// and time triggered rotations not affected.
+func WithMinRotateInterval(d time.Duration) Option {
+	return func(o *writerOptions) {
+		o.minRotateInterval = d
+	}
+}
+
+// Returns true if a size triggered rotation at now is too soon after the
+// last rotation, see WithMinRotateInterval(). Must hold w.l.
+func (w *fileLogWriter) rotateTooSoon(now time.Time) bool {
+	if w.minRotateInterval <= 0 {
+		return false
+	}
+	last := atomic.LoadInt64(&w.lastRotation)
+	if last == 0 || now.Sub(time.Unix(0, last)) >= w.minRotateInterval {
+		w.rotateSuppressed = false
+		return false
+	}
+
+	if !w.rotateSuppressed {
+		w.rotateSuppressed = true
+		w.reportError("rotate", fmt.Errorf("logging: rotation of %s suppressed, less than %s since last rotation", w.CurrentPath(), w.minRotateInterval))
+	}
+	return true
+}
+
+// Rotation failed, the live log file still open and writable, such as
+// failed to rename it.
+type rotateError struct {
+	err error
+}
+
+func (e *rotateError) Error() string { return e.err.Error() }
+
+func (e *rotateError) Unwrap() error { return e.err }
+
+// Time between retries of failed rotation by Write.
+const rotateRetryInterval = time.Second
+
+// Reopen the live log file of fname after failed to rename it to backup,
+// returns rotateError of err if reopened, err if not. Must hold w.l.
+func (w *fileLogWriter) reopenLiveLocked(fname string, err error) error {
+	f, openErr := w.openFile(fname)
+	if openErr != nil {
+		return err
+	}
+	w.f = f
+	return &rotateError{err}
+}
+
+// Returns true if err is rotateError, reports it, and rotation by Write not
+// retried in rotateRetryInterval, records keep written to the live log file
+// beyond limits, instead of recovery dropping records. Must hold w.l.
+func (w *fileLogWriter) rotateFailedLocked(err error) bool {
+	var re *rotateError
+	if !errors.As(err, &re) {
+		return false
+	}
+	w.reportError("rotate", fmt.Errorf("logging: rotate %s, keep writing to it: %w", w.CurrentPath(), re.err))
+	w.rotateRetryAt = hal.Now().Add(rotateRetryInterval)
+	return true
+}
+
+// Returns false if rotation by Write failed in rotateRetryInterval, see
+// rotateFailedLocked(). Must hold w.l.
+func (w *fileLogWriter) rotateAllowed() bool {
+	return !hal.Now().Before(w.rotateRetryAt)
+}
+
+// Rotate rotates the live log file now, regardless of rotation triggers. The
+// log file backed up, compressed and old backups cleaned in background, same
+// as size triggered rotation. Safe to call concurrently with Write.
+func (w *fileLogWriter) Rotate() error {
+	w.l.Lock()
+	err := w.rotateNowLocked()
+	w.l.Unlock()
+
+	if w.lazyOpen {
+		evictHandles(w)
+	}
+	return err
+}
+
+func (w *fileLogWriter) rotateNowLocked() error {
+	switch {
+	case w.closed:
+		return ErrWriterClosed
+	case w.frozen != nil:
+		return errWriterFrozen
+	}
+	if err := w.ensureOpen(); err != nil {
+		return err
+	}
+
+	bakFile := w.newBackupFilename(w.f.Name())
+	if debugEnabled() {
+		debugf("rotate: %s requested, backup to %s", w.CurrentPath(), bakFile)
+	}
+	now := hal.Now()
+	if err := w.rotateLocked(now, bakFile, atomic.LoadInt64(&w.currentSize)); err != nil {
+		w.failure.set(err)
+		return err
+	}
+	if w.calendar != nil {
+		w.periodStart = w.calendar.start(now)
+	}
+	return nil
+}
+
+// Rotate rotates the log file created by config, or the first alive file log
+// writer, see Rotate method of file log writer.
+func Rotate() error {
+	w, _ := defaultWriters()
+	if w == nil {
+		return ErrNoFileLogWriter
+	}
+	return w.Rotate()
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SearchOptions controls Search.
+type SearchOptions struct {
+	// Pattern is a regular expression if true, otherwise a substring.
+	Regexp bool
+
+	// Only search files may contain logs written in the time range, zero
+	// value means not bounded.
+	From, To time.Time
+
+	// Max archives open at the same time, default to 4.
+	MaxOpen int
+}
+
+// Match is a line matched by Search.
+type Match struct {
+	File   string
+	LineNo int // 1 based
+
+	// Line without trailing new line.
+	Line string
+
+	// Timestamp parsed from the line, or rotation time of the file if the line
+	// has no timestamp, zero for the live log file.
+	ApproxTime time.Time
+}
+
+// max length of a searched line, search of a file stops at a longer line
+const maxSearchLine = 1024 * 1024
+
+// Search searches lines match pattern in the live log file and archives,
+// newest files searched first. Files are searched concurrently, matches of
+// different files may interleave, matches of a file are in line order.
+// Channel closed after search done or ctx done.
+func (w *fileLogWriter) Search(ctx context.Context, pattern string, opts SearchOptions) (<-chan Match, error) {
+	return Search(ctx, w.CurrentPath(), pattern, opts)
+}
+
+// Search searches lines match pattern of log file at path, see Search method
+// of file log writer.
+func Search(ctx context.Context, path, pattern string, opts SearchOptions) (<-chan Match, error) {
+	match := func(line string) bool {
+		return strings.Contains(line, pattern)
+	}
+	if opts.Regexp {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		match = re.MatchString
+	}
+
+	to := opts.To
+	if to.IsZero() {
+		to = time.Unix(1<<62, 0)
+	}
+	files, err := rangeFiles(path, opts.From, to)
+	if err != nil {
+		return nil, err
+	}
+
+	maxOpen := opts.MaxOpen
+	if maxOpen <= 0 {
+		maxOpen = 4
+	}
+
+	ch := make(chan Match)
+	go func() {
+		defer close(ch)
+
+		sem := make(chan struct{}, maxOpen)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for i := len(files) - 1; i >= 0; i-- {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Add(1)
+			go func(f string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				searchFile(ctx, path, f, match, ch)
+			}(files[i])
+		}
+	}()
+	return ch, nil
+}
+
+func searchFile(ctx context.Context, path, file string, match func(string) bool, ch chan<- Match) {
+	lw := lookupFileWriter(path)
+	r, err := OpenArchiveKey(file, lw.key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			reportError("search", err)
+		}
+		return
+	}
+	defer safeClose("search", r)
+
+	var fileTime time.Time
+	if file != path {
+		fileTime = lw.backupTime(path, file)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxSearchLine)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if !match(line) {
+			continue
+		}
+
+		m := Match{File: file, LineNo: lineNo, Line: line, ApproxTime: fileTime}
+		if t, ok := parseLineTime(scanner.Bytes()); ok {
+			m.ApproxTime = t
+		}
+		select {
+		case ch <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		reportError("search", err)
+	}
+}
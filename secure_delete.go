@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// WithSecureDelete overwrites archives with zeros before deleted by retention
+// or purge, single pass, throttled to bytesPerSecond, not throttled if <= 0.
+// Files larger than maxSize are deleted without overwrite, no limit if
+// maxSize <= 0. If overwrite failed, file deleted normally and an internal
+// error reported.
+//
+// Caveat: overwrite in place is pointless on copy-on-write filesystems
+// (btrfs, zfs, bcachefs), or SSDs remapping blocks, overwrite skipped on
+// copy-on-write filesystems detected (linux only). Use encryption at rest,
+// see WithEncryption(), if that matters.
+func WithSecureDelete(maxSize, bytesPerSecond int64) Option {
+	return func(o *writerOptions) {
+		o.secureDelete = true
+		o.secureDeleteMax = maxSize
+		o.secureDeleteRate = bytesPerSecond
+	}
+}
+
+// Remove archive, overwrite first if secure delete enabled.
+func (w *fileLogWriter) removeArchive(path string) error {
+	if w.secureDelete {
+		if err := w.overwrite(path); err != nil && !os.IsNotExist(err) {
+			w.reportError("secure-delete", fmt.Errorf("overwrite %s failed, delete normally: %w", path, err))
+		}
+	}
+	return os.Remove(path)
+}
+
+func (w *fileLogWriter) overwrite(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer safeClose("secure-delete", f)
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if w.secureDeleteMax > 0 && info.Size() > w.secureDeleteMax {
+		return nil
+	}
+	if cow, err := isCopyOnWrite(path); err != nil || cow {
+		return err
+	}
+
+	if debugEnabled() {
+		debugf("secure-delete: overwrite %s, size %d", path, info.Size())
+	}
+	src := &throttledReader{
+		ctx:   context.Background(),
+		r:     io.LimitReader(zeroReader{}, info.Size()),
+		rate:  w.secureDeleteRate,
+		start: time.Now(),
+	}
+	if _, err = io.Copy(f, src); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// WithSigning signs archives by ed25519 after compressed and encrypted,
+// signature written to "<archive>.sig" as hex string, deleted with the
+// archive. Key returns 32 bytes seed or 64 bytes private key, KeyFile() can
+// be used.
+//
+// NewFileLogWriter fails if key returns error or invalid key.
+func WithSigning(key KeyFunc) Option {
+	return func(o *writerOptions) {
+		o.signKey = key
+	}
+}
+
+const sigSuffix = `.sig`
+
+var errSignatureMismatch = errors.New("logging: archive signature mismatch")
+
+func loadSigningKey(key KeyFunc) (ed25519.PrivateKey, error) {
+	k, err := key()
+	if err != nil {
+		return nil, err
+	}
+	switch len(k) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(k), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(k), nil
+	default:
+		return nil, fmt.Errorf("logging: signing key must be %d or %d bytes, got %d",
+			ed25519.SeedSize, ed25519.PrivateKeySize, len(k))
+	}
+}
+
+// Write signature of archive to archive.sig.
+func (w *fileLogWriter) signArchive(archive string) error {
+	key, err := loadSigningKey(w.signKey)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(archive)
+	if err != nil {
+		return err
+	}
+	sig := hex.EncodeToString(ed25519.Sign(key, content))
+	tmp := archive + sigSuffix + `.tmp`
+	if err = ioutil.WriteFile(tmp, []byte(sig+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, archive+sigSuffix)
+}
+
+// Remove sidecar files of archive.
+func removeSidecars(archive string) {
+	if err := os.Remove(archive + sigSuffix); err != nil && !os.IsNotExist(err) {
+		reportError("retention", err)
+	}
+}
+
+// Returns true if name is a sidecar or temp file, not a backup.
+func isSidecar(name string) bool {
+	return strings.HasSuffix(name, sigSuffix) || strings.HasSuffix(name, `.tmp`) ||
+		strings.HasSuffix(name, auditSuffix) || strings.HasSuffix(name, lockSuffix) ||
+		strings.HasSuffix(name, manifestSuffix)
+}
+
+// VerifySignatures verifies signatures of archives by pub, see WithSigning().
+// Result status is VerifyOK, VerifyCorrupt if signature mismatch, or
+// VerifyMissingSignature.
+func (w *fileLogWriter) VerifySignatures(ctx context.Context, pub ed25519.PublicKey) ([]VerifyResult, error) {
+	archives, err := w.listArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	var r []VerifyResult
+	for _, a := range archives {
+		if !a.Compressed {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+
+		start := time.Now()
+		res := VerifyResult{Path: a.Path, Size: a.Size}
+		sig, err := ioutil.ReadFile(a.Path + sigSuffix)
+		if os.IsNotExist(err) {
+			res.Status = VerifyMissingSignature
+		} else {
+			if err == nil {
+				err = verifySignature(a.Path, sig, pub)
+			}
+			if os.IsNotExist(err) {
+				// deleted by retention
+				continue
+			}
+			if err != nil {
+				res.Status, res.Err = VerifyCorrupt, err
+			}
+		}
+		res.Duration = time.Since(start)
+		r = append(r, res)
+	}
+	return r, nil
+}
+
+// VerifySignatures verifies signatures of archives of the log file created by
+// config, or the first alive file log writer, see VerifySignatures method of
+// file log writer.
+func VerifySignatures(ctx context.Context, pub ed25519.PublicKey) ([]VerifyResult, error) {
+	w, _ := defaultWriters()
+	if w == nil {
+		return nil, ErrNoFileLogWriter
+	}
+	return w.VerifySignatures(ctx, pub)
+}
+
+func verifySignature(archive string, sig []byte, pub ed25519.PublicKey) error {
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(archive)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, content, decoded) {
+		return errSignatureMismatch
+	}
+	return nil
+}
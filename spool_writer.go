@@ -0,0 +1,494 @@
+package logging
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	spoolSegmentPrefix = "spool-"
+	spoolSegmentExt    = ".seg"
+
+	// record header: 4 bytes payload length, 4 bytes crc32 of payload, big endian
+	spoolHeaderLen = 8
+
+	defaultSpoolSegmentSize = 4 * 1024 * 1024
+)
+
+// ErrWriterClosed returned by Write after the writer closed.
+var ErrWriterClosed = errors.New("logging: writer closed")
+
+// Store and forward writer, write log records to segment files in spool
+// directory, a background goroutine replays them to the inner writer in
+// order, segment file deleted after all its records written to inner writer.
+//
+// Log records not lost if inner writer (normally a network writer)
+// unavailable for a long time, until spool size exceeds maxBytes, then oldest
+// segments deleted.
+//
+// Each record prefixed with its length and checksum, torn writes skipped on
+// recovery. Records sent at least once: if crashed, records in the segment
+// being replayed may sent again on next start.
+//
+// Segment files are not fsynced every write, only when a segment is full.
+type spoolWriter struct {
+	// dropped counts records dropped because of exceeds maxBytes, written
+	// counts bytes sent to inner writer.
+	writerCounters
+	pending int64 // records in spool not sent, not count records left by last run
+
+	dir         string
+	inner       io.WriteCloser
+	maxBytes    int64
+	segmentSize int64
+
+	l      sync.Mutex
+	segs   []*spoolSegment // ordered by seq, last one is the one writing
+	f      *os.File        // the writing segment
+	total  int64           // total bytes of all segments
+	closed bool
+
+	notify chan struct{} // new record written
+	stopCh chan struct{} // closed on Close()
+	exitCh chan struct{} // closed when sender goroutine exit
+
+	failure failureState // sender state
+}
+
+type spoolSegment struct {
+	seq     uint64
+	size    int64
+	records int // records not sent, -1 if unknown
+}
+
+// NewSpoolWriter create a store and forward writer, records spooled to
+// segment files in dir, and send to inner writer in background. Records
+// in dir left by last run, are sent first.
+//
+// maxBytes limits size of spool directory, oldest segments deleted if
+// exceeded.
+func NewSpoolWriter(dir string, inner io.WriteCloser, maxBytes int64, opts ...Option) (io.WriteCloser, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("[%s] spool maxBytes must be positive: %d", tag, maxBytes)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	segSize := int64(defaultSpoolSegmentSize)
+	if segSize > maxBytes/4 {
+		segSize = maxBytes / 4
+	}
+	if segSize < spoolHeaderLen+1 {
+		segSize = spoolHeaderLen + 1
+	}
+
+	w := &spoolWriter{
+		dir:         dir,
+		inner:       inner,
+		maxBytes:    maxBytes,
+		segmentSize: segSize,
+		notify:      make(chan struct{}, 1),
+		stopCh:      make(chan struct{}),
+		exitCh:      make(chan struct{}),
+	}
+
+	o := newWriterOptions(opts)
+	w.id = writerID(o, "spool", dir)
+
+	if err := w.loadSegments(); err != nil {
+		return nil, err
+	}
+
+	// Always starts a new segment, never append to segments of last run,
+	// they may end with a torn record.
+	var seq uint64 = 1
+	if len(w.segs) > 0 {
+		seq = w.segs[len(w.segs)-1].seq + 1
+	}
+	if err := w.newSegment(seq); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+	registerWriter(w, o)
+	return w, nil
+}
+
+func (w *spoolWriter) loadSegments() error {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range files {
+		seq, ok := parseSpoolSegmentName(info.Name())
+		if !ok || info.IsDir() {
+			continue
+		}
+		w.segs = append(w.segs, &spoolSegment{seq, info.Size(), -1})
+		w.total += info.Size()
+	}
+	sort.Slice(w.segs, func(i, j int) bool {
+		return w.segs[i].seq < w.segs[j].seq
+	})
+	return nil
+}
+
+func parseSpoolSegmentName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, spoolSegmentPrefix) || !strings.HasSuffix(name, spoolSegmentExt) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(name[len(spoolSegmentPrefix):len(name)-len(spoolSegmentExt)], 10, 64)
+	return seq, err == nil
+}
+
+func (w *spoolWriter) segmentPath(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, seq, spoolSegmentExt))
+}
+
+// Create and open new segment, must called inside lock.
+func (w *spoolWriter) newSegment(seq uint64) error {
+	f, err := os.OpenFile(w.segmentPath(seq), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.segs = append(w.segs, &spoolSegment{seq: seq})
+	return nil
+}
+
+// Write implements io.Writer, append the record to spool.
+func (w *spoolWriter) Write(p []byte) (n int, err error) {
+	recLen := int64(len(p) + spoolHeaderLen)
+
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	if recLen > w.maxBytes {
+		w.drop(1)
+		return len(p), nil
+	}
+
+	active := w.segs[len(w.segs)-1]
+	if active.size > 0 && active.size+recLen > w.segmentSize {
+		if err = w.rollSegment(); err != nil {
+			return 0, err
+		}
+		active = w.segs[len(w.segs)-1]
+	}
+
+	w.truncate(recLen)
+
+	buf := make([]byte, recLen)
+	binary.BigEndian.PutUint32(buf, uint32(len(p)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(p))
+	copy(buf[spoolHeaderLen:], p)
+	written, err := w.f.Write(buf)
+	active.size += int64(written)
+	active.records++
+	atomic.AddInt64(&w.pending, 1)
+	w.total += int64(written)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// Close current segment and start a new one, must called inside lock.
+func (w *spoolWriter) rollSegment() error {
+	if err := w.f.Sync(); err != nil {
+		w.reportError("spool", err)
+	}
+	if err := w.f.Close(); err != nil {
+		w.reportError("spool", err)
+	}
+	return w.newSegment(w.segs[len(w.segs)-1].seq + 1)
+}
+
+// Delete oldest segments to make room for n bytes, the writing segment never
+// deleted. Must called inside lock.
+func (w *spoolWriter) truncate(n int64) {
+	for w.total+n > w.maxBytes && len(w.segs) > 1 {
+		seg := w.segs[0]
+		w.segs = w.segs[1:]
+		w.total -= seg.size
+		if seg.records > 0 {
+			w.drop(int64(seg.records))
+			atomic.AddInt64(&w.pending, -int64(seg.records))
+		}
+		if err := os.Remove(w.segmentPath(seg.seq)); err != nil && !os.IsNotExist(err) {
+			w.reportError("spool", err)
+		}
+	}
+}
+
+// Close stops the sender after it tries once to send the spooled records, the
+// records not sent remain in spool directory, and sent on next start. Inner
+// writer closed as well.
+func (w *spoolWriter) Close() error {
+	w.l.Lock()
+	if w.closed {
+		w.l.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.l.Unlock()
+
+	close(w.stopCh)
+	<-w.exitCh
+	unregisterWriter(w)
+
+	w.l.Lock()
+	err := w.f.Close()
+	w.l.Unlock()
+
+	if e := w.inner.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// Sender goroutine.
+func (w *spoolWriter) run() {
+	defer close(w.exitCh)
+
+	c := &spoolCursor{}
+	defer c.close()
+
+	backoff := time.Duration(0)
+	for {
+		rec, ok := w.next(c)
+		if !ok {
+			return
+		}
+
+		if _, err := w.inner.Write(rec); err != nil {
+			atomic.AddInt64(&w.innerErrors, 1)
+			w.failure.set(err)
+			if backoff == 0 {
+				w.reportError("spool", fmt.Errorf("send failed, will retry: %s", err))
+			}
+			if !w.sleep(&backoff) {
+				return
+			}
+			continue
+		}
+		backoff = 0
+		w.failure.clear()
+		c.off += int64(len(rec) + spoolHeaderLen)
+		atomic.AddInt64(&w.written, int64(len(rec)))
+		w.sent(c)
+	}
+}
+
+// Sleep before retry, returns false if writer closed.
+func (w *spoolWriter) sleep(backoff *time.Duration) bool {
+	const maxBackoff = 30 * time.Second
+	switch {
+	case *backoff == 0:
+		*backoff = 100 * time.Millisecond
+	case *backoff < maxBackoff:
+		*backoff *= 2
+	}
+	if *backoff > maxBackoff {
+		*backoff = maxBackoff
+	}
+
+	select {
+	case <-w.stopCh:
+		return false
+	case <-time.After(*backoff):
+		return true
+	}
+}
+
+type spoolCursor struct {
+	seq uint64
+	off int64
+	f   *os.File
+}
+
+func (c *spoolCursor) close() {
+	if c.f != nil {
+		safeClose("spool", c.f)
+		c.f = nil
+	}
+}
+
+// Returns next record to send, blocks until a record available. Returns false
+// if writer closed and no more records.
+func (w *spoolWriter) next(c *spoolCursor) ([]byte, bool) {
+	for {
+		seg, size, active := w.cursorSegment(c)
+		if c.off >= size {
+			if !active {
+				c.close()
+				w.removeSegment(seg)
+				continue
+			}
+
+			select {
+			case <-w.notify:
+				continue
+			case <-w.stopCh:
+				// try last time, record may written just before close
+				if _, newSize, _ := w.cursorSegment(c); c.off < newSize {
+					continue
+				}
+				return nil, false
+			}
+		}
+
+		rec, err := c.read(w.segmentPath(seg.seq), size)
+		if err != nil {
+			// torn or corrupted record, skip the rest of this segment
+			w.reportError("spool", fmt.Errorf("segment %d corrupted at %d, skipped: %s", seg.seq, c.off, err))
+			c.off = size
+			if active {
+				// writing segment is never torn, unless disk error, start a new segment
+				w.l.Lock()
+				if !w.closed && w.segs[len(w.segs)-1] == seg {
+					if err := w.rollSegment(); err != nil {
+						w.reportError("spool", err)
+					}
+				}
+				w.l.Unlock()
+			}
+			continue
+		}
+		return rec, true
+	}
+}
+
+// Locate the segment cursor reading, move cursor to first segment if the
+// segment cursor pointing already deleted.
+func (w *spoolWriter) cursorSegment(c *spoolCursor) (seg *spoolSegment, size int64, active bool) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	for i, s := range w.segs {
+		if s.seq >= c.seq {
+			if s.seq != c.seq {
+				c.close()
+				c.seq, c.off = s.seq, 0
+			}
+			return s, s.size, i == len(w.segs)-1
+		}
+	}
+	panic("spool writing segment missing")
+}
+
+func (w *spoolWriter) removeSegment(seg *spoolSegment) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	for i, s := range w.segs {
+		if s == seg {
+			w.segs = append(w.segs[:i], w.segs[i+1:]...)
+			w.total -= s.size
+			if err := os.Remove(w.segmentPath(s.seq)); err != nil && !os.IsNotExist(err) {
+				w.reportError("spool", err)
+			}
+			return
+		}
+	}
+}
+
+var errSpoolTorn = errors.New("torn record")
+
+// Read record at cursor offset, size is the valid length of the segment.
+func (c *spoolCursor) read(path string, size int64) ([]byte, error) {
+	if c.f == nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		c.f = f
+	}
+
+	if c.off+spoolHeaderLen > size {
+		return nil, errSpoolTorn
+	}
+	var header [spoolHeaderLen]byte
+	if _, err := c.f.ReadAt(header[:], c.off); err != nil {
+		return nil, err
+	}
+
+	n := int64(binary.BigEndian.Uint32(header[:]))
+	if c.off+spoolHeaderLen+n > size {
+		return nil, errSpoolTorn
+	}
+	rec := make([]byte, n)
+	if _, err := c.f.ReadAt(rec, c.off+spoolHeaderLen); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(rec) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return rec, nil
+}
+
+// Update not sent records count of cursor segment.
+func (w *spoolWriter) sent(c *spoolCursor) {
+	w.l.Lock()
+	defer w.l.Unlock()
+
+	for _, s := range w.segs {
+		if s.seq == c.seq {
+			if s.records > 0 {
+				s.records--
+				atomic.AddInt64(&w.pending, -1)
+			}
+			return
+		}
+	}
+}
+
+func (w *spoolWriter) stats() WriterStats {
+	r := w.snapshot("spool", w.dir)
+	r.QueueDepth = int(atomic.LoadInt64(&w.pending))
+	return r
+}
+
+func (w *spoolWriter) workerStatus() string {
+	running := true
+	select {
+	case <-w.exitCh:
+		running = false
+	default:
+	}
+
+	w.l.Lock()
+	segs, total := len(w.segs), w.total
+	w.l.Unlock()
+	return fmt.Sprintf("sender running: %v, segments: %d, spool bytes: %d", running, segs, total)
+}
+
+// Records are spooled if inner writer failing, degraded after
+// SinkDownThreshold.
+func (w *spoolWriter) health() *Problem {
+	return w.failure.problem(&w.writerCounters, "spool", w.dir, Degraded, SinkDownThreshold)
+}
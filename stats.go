@@ -0,0 +1,244 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/redforks/hal"
+)
+
+// WriterStats is statistics snapshot of a writer created by this package.
+type WriterStats struct {
+	ID   string // writer ID, see WithName()
+	Kind string // writer type: "file", "async", "failover", "spool"
+	Path string // log file path or spool directory, empty if not apply
+
+	Written          int64 // bytes written to the writer's target
+	Dropped          int64 // messages dropped
+	Rotations        int64 // log file rotation count
+	CompressFailures int64 // failed archive compressions
+	InnerErrors      int64 // errors reported by inner writers
+	FileSize         int64 // current log file size
+	QueueDepth       int   // messages waiting in queue
+	QueueMax         int   // max queue depth ever reached
+	QueueCap         int   // queue capacity
+
+	LastRotation time.Time // zero if never rotated
+}
+
+// FileStats is statistics of a log file, its archives and runtime counters.
+type FileStats struct {
+	ID             string    // writer ID
+	Path           string    // the live log file
+	Size           int64     // size of the live log file
+	Archives       int       // number of compressed archives
+	ArchivesSize   int64     // total size of compressed archives
+	PendingBackups int       // number of backups not compressed yet
+	OldestArchive  time.Time // rotation time of oldest archive, zero if no archives
+	NewestArchive  time.Time // rotation time of newest archive, zero if no archives
+	NewestPath     string    // path of newest archive, empty if no archives
+
+	Written      int64     // bytes written since the writer created
+	Dropped      int64     // messages dropped
+	Rotations    int64     // rotations since the writer created
+	LastRotation time.Time // zero if never rotated
+
+	FollowDropped int64 // lines dropped by slow Follow() consumers
+	EventsDropped int64 // rotation events dropped by slow consumer, see WithRotationEvents()
+
+	FailedCompressions int // backups failed to compress, waiting for retry
+}
+
+// Internal statistics counters of a writer, all fields accessed by atomic
+// operations. Must be the first field of the containing struct to keep 64-bit
+// alignment.
+type writerCounters struct {
+	written          int64
+	dropped          int64
+	rotations        int64
+	compressFailures int64
+	innerErrors      int64
+	currentSize      int64
+
+	// unix nano of first and last drop, first reset by drop events aggregator
+	firstDropAt, lastDropAt int64
+
+	lastRotation int64 // unix nano
+	queueMax     int64
+
+	id string // writer ID, not changed after the writer created
+}
+
+// Record n messages dropped.
+func (c *writerCounters) drop(n int64) {
+	atomic.AddInt64(&c.dropped, n)
+	now := hal.Now().UnixNano()
+	atomic.CompareAndSwapInt64(&c.firstDropAt, 0, now)
+	atomic.StoreInt64(&c.lastDropAt, now)
+}
+
+func (c *writerCounters) counters() *writerCounters {
+	return c
+}
+
+// Report internal error of the writer, error wrapped by WriterError.
+func (c *writerCounters) reportError(component string, err error) {
+	reportError(component, &WriterError{c.id, err})
+}
+
+// WriterError wraps internal error with ID of the writer reports it.
+type WriterError struct {
+	Writer string // writer ID
+	Err    error
+}
+
+func (e *WriterError) Error() string {
+	return e.Writer + ": " + e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *WriterError) Unwrap() error {
+	return e.Err
+}
+
+var writerSeq int64
+
+// Generate writer ID, see WithName().
+func writerID(o *writerOptions, kind, path string) string {
+	switch {
+	case o.name != "":
+		return o.name
+	case path != "":
+		return kind + ":" + path
+	default:
+		return fmt.Sprintf("%s-%d", kind, atomic.AddInt64(&writerSeq, 1))
+	}
+}
+
+func (c *writerCounters) snapshot(kind, path string) WriterStats {
+	return WriterStats{
+		ID:               c.id,
+		Kind:             kind,
+		Path:             path,
+		Written:          atomic.LoadInt64(&c.written),
+		Dropped:          atomic.LoadInt64(&c.dropped),
+		Rotations:        atomic.LoadInt64(&c.rotations),
+		CompressFailures: atomic.LoadInt64(&c.compressFailures),
+		InnerErrors:      atomic.LoadInt64(&c.innerErrors),
+		FileSize:         atomic.LoadInt64(&c.currentSize),
+		QueueMax:         int(atomic.LoadInt64(&c.queueMax)),
+		LastRotation:     unixNanoTime(atomic.LoadInt64(&c.lastRotation)),
+	}
+}
+
+// Record a rotation.
+func (c *writerCounters) rotated() {
+	atomic.AddInt64(&c.rotations, 1)
+	atomic.StoreInt64(&c.lastRotation, hal.Now().UnixNano())
+}
+
+// Update max queue depth.
+func (c *writerCounters) queued(depth int) {
+	for {
+		max := atomic.LoadInt64(&c.queueMax)
+		if int64(depth) <= max || atomic.CompareAndSwapInt64(&c.queueMax, max, int64(depth)) {
+			return
+		}
+	}
+}
+
+// Convert unix nano to time, zero time if 0.
+func unixNanoTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// AllWriterStats returns statistics of all alive writers created by this
+// package.
+func AllWriterStats() []WriterStats {
+	writers := registeredWriters()
+	r := make([]WriterStats, len(writers))
+	for i, w := range writers {
+		r[i] = w.stats()
+	}
+	return r
+}
+
+// Instrumentation receives timing events from writers, for metrics need more
+// than counters of WriterStats. Methods may called concurrently.
+type Instrumentation interface {
+	// Rotated called after log file rotated, d is time spent on rename and
+	// reopen the log file.
+	Rotated(s WriterStats, d time.Duration)
+
+	// Compressed called after an archive compression done, err is nil if
+	// succeed.
+	Compressed(s WriterStats, d time.Duration, err error)
+}
+
+// holds instrumentationBox
+var instrumentationValue atomic.Value
+
+// atomic.Value can not store nil interface
+type instrumentationBox struct {
+	i Instrumentation
+}
+
+// SetInstrumentation set Instrumentation receives writer events, nil to
+// disable. No overhead except a nil check if not set.
+func SetInstrumentation(i Instrumentation) {
+	instrumentationValue.Store(instrumentationBox{i})
+}
+
+func instrumentation() Instrumentation {
+	if v, ok := instrumentationValue.Load().(instrumentationBox); ok {
+		return v.i
+	}
+	return nil
+}
+
+// ErrNoFileLogWriter returned if no file log writer exist.
+var ErrNoFileLogWriter = errors.New("logging: no file log writer")
+
+var (
+	// writers created by option.Init
+	defaultFileWriter  *fileLogWriter
+	defaultAsyncWriter *asyncLogWriter
+)
+
+// Stats returns FileStats of the log file created by config, or the first
+// alive file log writer if logging not initialized by config. Messages
+// dropped by async writer of config included.
+func Stats() (FileStats, error) {
+	w, async := defaultWriters()
+	if w == nil {
+		return FileStats{}, ErrNoFileLogWriter
+	}
+
+	r, err := w.Stats()
+	if async != nil {
+		r.Dropped += atomic.LoadInt64(&async.dropped)
+	}
+	return r, err
+}
+
+// Returns writers created by config, or the first alive file log writer if
+// logging not initialized by config, w is nil if no file log writer.
+func defaultWriters() (w *fileLogWriter, async *asyncLogWriter) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	w, async = defaultFileWriter, defaultAsyncWriter
+	if w == nil {
+		for _, item := range registry {
+			if fw, ok := item.w.(*fileLogWriter); ok {
+				return fw, nil
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Returns status line summarizing statistics of writers created by config, ok
+// is false if file logging not enabled.
+func statusLine() (line string, ok bool) {
+	s, err := Stats()
+	if err != nil {
+		return "", false
+	}
+
+	line = fmt.Sprintf("status: %s written, %d dropped, %d rotations", formatBytes(s.Written), s.Dropped, s.Rotations)
+
+	registryLock.Lock()
+	async := defaultAsyncWriter
+	registryLock.Unlock()
+	if async != nil {
+		q := async.stats()
+		line += fmt.Sprintf(", queue max %d/%d", q.QueueMax, q.QueueCap)
+	}
+
+	if !s.LastRotation.IsZero() {
+		line += ", last rotation " + s.LastRotation.UTC().Format("2006-01-02T15:04Z")
+	}
+	return line, true
+}
+
+// Stops status loop started by last Init, nil if not running. Protected by
+// registryLock.
+var statusStop chan struct{}
+
+// Start status loop of interval, until async writer closed or next Init.
+// Status loop of last Init stopped, not started if interval <= 0.
+func startStatus(interval time.Duration, async *asyncLogWriter) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	stopStatusLocked()
+	if interval <= 0 || async == nil {
+		return
+	}
+	statusStop = make(chan struct{})
+	go logStatus(interval, statusStop, async.exitCh)
+}
+
+// Must hold registryLock.
+func stopStatusLocked() {
+	if statusStop != nil {
+		close(statusStop)
+		statusStop = nil
+	}
+}
+
+// Log status line every interval, through the normal log pipeline. Runs
+// until stop or closed closed.
+func logStatus(interval time.Duration, stop, closed <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if line, ok := statusLine(); ok {
+				log.Printf("[%s] %s", tag, line)
+			}
+		case <-stop:
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%d%cB", n/div, "KMGTPE"[exp])
+}
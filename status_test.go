@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusLoopStopped(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(first *asyncLogWriter)
+	}{
+		{"re-init", func(*asyncLogWriter) {
+			startStatus(time.Hour, &asyncLogWriter{exitCh: make(chan struct{})})
+		}},
+		{"re-init without file", func(*asyncLogWriter) { startStatus(0, nil) }},
+		{"writer closed", func(first *asyncLogWriter) { close(first.exitCh) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := &asyncLogWriter{exitCh: make(chan struct{})}
+			startStatus(time.Hour, first)
+			registryLock.Lock()
+			stop := statusStop
+			registryLock.Unlock()
+
+			done := make(chan struct{})
+			go func() {
+				logStatus(time.Hour, stop, first.exitCh)
+				close(done)
+			}()
+			tt.stop(first)
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("status loop not stopped")
+			}
+
+			registryLock.Lock()
+			stopStatusLocked()
+			registryLock.Unlock()
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// VerifyStatus is the result status of an archive verification.
+type VerifyStatus int
+
+const (
+	// VerifyOK means the archive is a valid compressed file, and matches its
+	// recorded checksum.
+	VerifyOK VerifyStatus = iota
+
+	// VerifyMissingChecksum means the archive is a valid compressed file, but no
+	// checksum recorded.
+	VerifyMissingChecksum
+
+	// VerifyCorrupt means the archive is not a valid compressed file, or checksum
+	// or signature mismatch.
+	VerifyCorrupt
+
+	// VerifyMissingSignature means the archive has no signature, returned by
+	// VerifySignatures().
+	VerifyMissingSignature
+)
+
+func (s VerifyStatus) String() string {
+	switch s {
+	case VerifyOK:
+		return "ok"
+	case VerifyMissingChecksum:
+		return "missing-checksum"
+	case VerifyCorrupt:
+		return "corrupt"
+	case VerifyMissingSignature:
+		return "missing-signature"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s VerifyStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// VerifyResult is the verification result of an archive.
+type VerifyResult struct {
+	Path     string
+	Status   VerifyStatus
+	Size     int64         // archive file size
+	Duration time.Duration // time spent
+	Err      error         // reason of corrupt, nil if not corrupt
+}
+
+// VerifyArchives checks integrity of compressed archives, by decrypting and
+// decompressing to the end, and validates checksum recorded by audit log and
+// manifest, see WithManifest(). Reading throttled to bytesPerSecond, not
+// throttled if <= 0.
+func (w *fileLogWriter) VerifyArchives(ctx context.Context, bytesPerSecond int64) ([]VerifyResult, error) {
+	archives, err := w.listArchives()
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := w.archiveChecksums(w.CurrentPath())
+	var r []VerifyResult
+	for _, a := range archives {
+		if !a.Compressed {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return r, err
+		}
+
+		start := time.Now()
+		res := VerifyResult{Path: a.Path, Size: a.Size}
+		// archives of custom compressors verified by checksum only
+		decompress := codecBySuffix(w.archiveSuffix(a.Path)) != nil
+		sum, err := verifyArchive(ctx, a.Path, w.key, bytesPerSecond, decompress)
+		switch {
+		case os.IsNotExist(err):
+			// deleted by retention
+			continue
+		case err == context.Canceled || err == context.DeadlineExceeded:
+			return r, err
+		case err != nil:
+			res.Status, res.Err = VerifyCorrupt, err
+		case checksums[a.Path] == "":
+			res.Status = VerifyMissingChecksum
+		case checksums[a.Path] != sum:
+			res.Status, res.Err = VerifyCorrupt, errChecksumMismatch
+		}
+		res.Duration = time.Since(start)
+		r = append(r, res)
+	}
+	return r, nil
+}
+
+// VerifyArchives verifies archives of the log file created by config, or the
+// first alive file log writer, see VerifyArchives method of file log writer.
+func VerifyArchives(ctx context.Context, bytesPerSecond int64) ([]VerifyResult, error) {
+	w, _ := defaultWriters()
+	if w == nil {
+		return nil, ErrNoFileLogWriter
+	}
+	return w.VerifyArchives(ctx, bytesPerSecond)
+}
+
+var (
+	errChecksumMismatch = errors.New("logging: archive checksum mismatch")
+	errUnknownCodec     = errors.New("logging: archive not compressed by a known codec")
+)
+
+// Decrypt and decompress file to the end, returns sha256 hex string of the
+// file. Not decompressed if decompress is false.
+func verifyArchive(ctx context.Context, path string, key KeyFunc, bytesPerSecond int64, decompress bool) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer safeClose("verify", f)
+
+	h := sha256.New()
+	src := bufio.NewReader(&throttledReader{ctx: ctx, r: io.TeeReader(f, h), rate: bytesPerSecond, start: time.Now()})
+	plain := src
+	if isEncrypted(src) {
+		dr, err := newDecryptReader(src, key)
+		if err != nil {
+			return "", err
+		}
+		plain = bufio.NewReader(dr)
+	}
+
+	if decompress {
+		c, err := sniffCodec(plain)
+		if err != nil {
+			return "", err
+		}
+		if c == nil {
+			return "", errUnknownCodec
+		}
+		dec, err := c.newReader(plain)
+		if err != nil {
+			return "", err
+		}
+		if _, err = io.Copy(ioutil.Discard, dec); err != nil {
+			return "", err
+		}
+		if err = dec.Close(); err != nil {
+			return "", err
+		}
+	}
+	// drain trailing bytes not read by decompressor for checksum.
+	if _, err = io.Copy(ioutil.Discard, plain); err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(ioutil.Discard, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Limits read rate to bytes per second, not limited if rate <= 0. Returns ctx
+// error if ctx done.
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	rate  int64
+	start time.Time
+	n     int64 // bytes read
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	var wait time.Duration
+	if t.rate > 0 {
+		if int64(len(p)) > t.rate {
+			p = p[:t.rate]
+		}
+		wait = time.Duration(t.n*int64(time.Second)/t.rate) - time.Since(t.start)
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			timer.Stop()
+			return 0, t.ctx.Err()
+		}
+	} else if err := t.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+	return n, err
+}
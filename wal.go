@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WithWriteAhead makes async writer append each record to write-ahead file
+// at path and fsync, before queued, so records queued not lost if process
+// crashed. Records not consumed by inner writer are replayed on next start,
+// may duplicate records written just before crash.
+//
+// maxBytes limits size of write-ahead file, consumed records dropped if
+// exceeded, records can not fit are queued without write-ahead. Write-ahead
+// disabled and an internal error reported if maxBytes <= 0. Off by default,
+// because fsync every record is expensive.
+func WithWriteAhead(path string, maxBytes int64) Option {
+	return func(o *writerOptions) {
+		o.walPath, o.walMaxBytes = path, maxBytes
+	}
+}
+
+const (
+	walOffsetSuffix = `.offset`
+
+	// file header: 8 bytes logical offset of the first record, big endian
+	walHeaderLen = 8
+)
+
+// Write-ahead file of async writer. Records use the same format as spool
+// segment: length and crc32 header, followed by payload, after file header.
+// Logical offset of first record not consumed persisted in "<path>.offset".
+//
+// Offsets of queued records are logical, file offset is logical minus base,
+// so they keep valid after the file compacted. Compaction replaces the file
+// with its rest records and new base atomically, offset persisted before
+// still valid, crash at any point neither skips nor replays consumed records.
+type writeAheadLog struct {
+	l        sync.Mutex
+	path     string
+	f        *os.File
+	maxBytes int64
+
+	base     int64 // logical offset of the first record in file
+	size     int64 // size of records, file header excluded
+	consumed int64 // offset of first record not consumed, relative to base
+}
+
+func openWriteAheadLog(path string, maxBytes int64) (*writeAheadLog, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("logging: invalid write-ahead max bytes %d", maxBytes)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	wal := &writeAheadLog{path: path, f: f, maxBytes: maxBytes}
+	info, err := f.Stat()
+	if err != nil {
+		safeClose("wal", f)
+		return nil, err
+	}
+	var buf [8]byte
+	if info.Size() < walHeaderLen {
+		// new file, or crashed before header written
+		if err := wal.replaceFile(nil, 0); err != nil {
+			safeClose("wal", wal.f)
+			return nil, err
+		}
+	} else {
+		if _, err := f.ReadAt(buf[:], 0); err != nil {
+			safeClose("wal", f)
+			return nil, err
+		}
+		wal.base = int64(binary.BigEndian.Uint64(buf[:]))
+		wal.size = info.Size() - walHeaderLen
+	}
+
+	if content, err := ioutil.ReadFile(path + walOffsetSuffix); err == nil && len(content) == len(buf) {
+		wal.consumed = int64(binary.BigEndian.Uint64(content)) - wal.base
+	}
+	switch {
+	case wal.consumed < 0:
+		wal.consumed = 0
+	case wal.consumed > wal.size:
+		wal.consumed = wal.size
+	}
+	return wal, nil
+}
+
+// Write records not consumed to w, stops at torn or corrupted record. Then
+// discard all records.
+func (wal *writeAheadLog) replay(w io.Writer) (records int, err error) {
+	content, err := ioutil.ReadAll(io.NewSectionReader(wal.f, walHeaderLen+wal.consumed, wal.size-wal.consumed))
+	if err != nil {
+		return 0, err
+	}
+
+	for len(content) >= spoolHeaderLen {
+		n := int(binary.BigEndian.Uint32(content))
+		if len(content) < spoolHeaderLen+n {
+			break
+		}
+		rec := content[spoolHeaderLen : spoolHeaderLen+n]
+		if crc32.ChecksumIEEE(rec) != binary.BigEndian.Uint32(content[4:]) {
+			break
+		}
+		if _, err = w.Write(rec); err != nil {
+			return records, err
+		}
+		records++
+		content = content[spoolHeaderLen+n:]
+	}
+	return records, wal.replaceFile(nil, wal.base+wal.size)
+}
+
+// Replace the file by a temp file of header of base followed by rest
+// records, then rename.
+func (wal *writeAheadLog) replaceFile(rest []byte, base int64) error {
+	content := make([]byte, walHeaderLen+len(rest))
+	binary.BigEndian.PutUint64(content, uint64(base))
+	copy(content[walHeaderLen:], rest)
+	tmp := wal.path + `.tmp`
+	if err := ioutil.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, wal.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(wal.path))
+
+	f, err := os.OpenFile(wal.path, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	safeClose("wal", wal.f)
+	wal.f = f
+	wal.base, wal.size, wal.consumed = base, int64(len(rest)), 0
+	return nil
+}
+
+// Persist logical offset of first record not consumed, by temp file then
+// rename.
+func (wal *writeAheadLog) saveOffset() error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(wal.base+wal.consumed))
+	path := wal.path + walOffsetSuffix
+	tmp := path + `.tmp`
+	if err := ioutil.WriteFile(tmp, buf[:], 0600); err != nil {
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Append record and fsync, returns logical end offset of the record, 0 if
+// record not appended.
+func (wal *writeAheadLog) append(p []byte) (int64, error) {
+	wal.l.Lock()
+	defer wal.l.Unlock()
+
+	recLen := int64(spoolHeaderLen + len(p))
+	if wal.size+recLen > wal.maxBytes {
+		if err := wal.compact(); err != nil {
+			return 0, err
+		}
+		if wal.size+recLen > wal.maxBytes {
+			return 0, nil
+		}
+	}
+
+	buf := make([]byte, recLen)
+	binary.BigEndian.PutUint32(buf, uint32(len(p)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(p))
+	copy(buf[spoolHeaderLen:], p)
+	if _, err := wal.f.WriteAt(buf, walHeaderLen+wal.size); err != nil {
+		return 0, err
+	}
+	if err := wal.f.Sync(); err != nil {
+		return 0, err
+	}
+	wal.size += recLen
+	return wal.base + wal.size, nil
+}
+
+// Drop consumed records. Must hold wal.l.
+func (wal *writeAheadLog) compact() error {
+	if wal.consumed == 0 {
+		return nil
+	}
+
+	rest := make([]byte, wal.size-wal.consumed)
+	if _, err := wal.f.ReadAt(rest, walHeaderLen+wal.consumed); err != nil {
+		return err
+	}
+	return wal.replaceFile(rest, wal.base+wal.consumed)
+}
+
+// Mark records until logical offset end consumed.
+func (wal *writeAheadLog) consume(end int64) {
+	wal.l.Lock()
+	defer wal.l.Unlock()
+
+	if off := end - wal.base; off > wal.consumed && off <= wal.size {
+		wal.consumed = off
+		if err := wal.saveOffset(); err != nil {
+			reportError("wal", err)
+		}
+	}
+}
+
+// Close the file, discard records if all consumed.
+func (wal *writeAheadLog) close() {
+	wal.l.Lock()
+	defer wal.l.Unlock()
+
+	if wal.consumed == wal.size && wal.size > 0 {
+		if err := wal.replaceFile(nil, wal.base+wal.size); err != nil {
+			reportError("wal", err)
+		}
+	}
+	safeClose("wal", wal.f)
+}
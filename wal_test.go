@@ -0,0 +1,166 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteAheadReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		consumed int  // records consumed before crash
+		compact  bool // compacted before crash
+		tornTemp bool // crashed while writing compacted temp file
+		want     string
+	}{
+		{"none consumed", 0, false, false, "r1\nr2\nr3\n"},
+		{"consumed", 1, false, false, "r2\nr3\n"},
+		{"all consumed", 3, false, false, ""},
+		{"compacted", 1, true, false, "r2\nr3\n"},
+		{"compacted all", 3, true, false, ""},
+		{"torn compaction", 1, false, true, "r2\nr3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.wal")
+			wal, err := openWriteAheadLog(path, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ends []int64
+			for i := 1; i <= 3; i++ {
+				end, err := wal.append([]byte(fmt.Sprintf("r%d\n", i)))
+				if err != nil || end == 0 {
+					t.Fatalf("append: %d, %v", end, err)
+				}
+				ends = append(ends, end)
+			}
+			if tt.consumed > 0 {
+				wal.consume(ends[tt.consumed-1])
+			}
+			if tt.compact {
+				wal.l.Lock()
+				err = wal.compact()
+				wal.l.Unlock()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tornTemp {
+				if err := ioutil.WriteFile(path+`.tmp`, []byte("torn"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// crash, not closed
+			safeClose("wal", wal.f)
+
+			wal, err = openWriteAheadLog(path, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer wal.close()
+			var buf bytes.Buffer
+			if _, err := wal.replay(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("replayed %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+// Offsets queued before compaction valid after it.
+func TestWriteAheadCompactKeepsOffsets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.wal")
+	rec := []byte("record\n")
+	recLen := int64(spoolHeaderLen + len(rec))
+	wal, err := openWriteAheadLog(path, 3*recLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ends []int64
+	for i := 0; i < 3; i++ {
+		end, _ := wal.append(rec)
+		ends = append(ends, end)
+	}
+	wal.consume(ends[0])
+	// full, compacted to make room
+	end, err := wal.append(rec)
+	if err != nil || end != ends[2]+recLen {
+		t.Fatalf("append after compact: %d, %v", end, err)
+	}
+	wal.consume(ends[2])
+	safeClose("wal", wal.f)
+
+	wal, err = openWriteAheadLog(path, 3*recLen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.close()
+	var buf bytes.Buffer
+	if n, err := wal.replay(&buf); err != nil || n != 1 {
+		t.Errorf("replayed %d records, %v, want 1", n, err)
+	}
+}
+
+func TestWriteAheadInvalidMaxBytes(t *testing.T) {
+	for _, maxBytes := range []int64{0, -1} {
+		path := filepath.Join(t.TempDir(), "app.wal")
+		if _, err := openWriteAheadLog(path, maxBytes); err == nil {
+			t.Errorf("max bytes %d accepted", maxBytes)
+		}
+	}
+
+	w := newTestAsyncWriter(t, &memWriter{}, WithWriteAhead(filepath.Join(t.TempDir(), "app.wal"), 0))
+	if w.wal != nil {
+		t.Error("write-ahead enabled with max bytes 0")
+	}
+}
+
+// Synced writes fill the queue, producers of write-ahead not deadlock with
+// consumer.
+func TestWriteAheadFullQueue(t *testing.T) {
+	inner := &memWriter{gate: make(chan struct{})}
+	path := filepath.Join(t.TempDir(), "app.wal")
+	w := newTestAsyncWriter(t, inner, WithSyncOnError(), WithWriteAhead(path, 1024*1024))
+
+	// consumer blocked on the first record
+	if _, err := w.Write([]byte("[INFO] first\n")); err != nil {
+		t.Fatal(err)
+	}
+	for len(w.ch) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < 2*cap(w.ch); i++ {
+			level := "INFO"
+			if i%2 == 0 {
+				level = "ERROR"
+			}
+			wg.Add(1)
+			go func(level string, i int) {
+				defer wg.Done()
+				_, _ = fmt.Fprintf(w, "[%s] %d\n", level, i)
+			}(level, i)
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(inner.gate)
+		wg.Wait()
+		_ = w.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("deadlocked")
+	}
+}